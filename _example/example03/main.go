@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-contrib/timeout"
+	"github.com/gin-gonic/gin"
+)
+
+func logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		log.Printf("%s %s status=%d timed_out=%t elapsed=%s",
+			c.Request.Method, c.Request.URL.Path, c.Writer.Status(),
+			timeout.IsTimedOut(c), timeout.Elapsed(c))
+	}
+}
+
+func main() {
+	r := gin.New()
+	r.Use(logger())
+	r.Use(timeout.New(
+		timeout.WithTimeout(500*time.Millisecond),
+		timeout.WithHandler(func(c *gin.Context) {
+			c.Next()
+		}),
+	))
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(800 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	if err := r.Run(":8080"); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package timeout
+
+import "sync/atomic"
+
+// handler goroutine states, see abandon and release
+const (
+	handlerRunning int32 = iota
+	handlerFinished
+	handlerAbandoned
+)
+
+// abandon marks a still running handler as abandoned after a timeout
+func (t *Timeout) abandon(state *atomic.Int32) {
+	if !state.CompareAndSwap(handlerRunning, handlerAbandoned) {
+		return
+	}
+
+	n := t.abandoned.Add(1)
+	for {
+		hw := t.abandonedHighWater.Load()
+		if n <= hw || t.abandonedHighWater.CompareAndSwap(hw, n) {
+			break
+		}
+	}
+	if t.abandonedThreshold > 0 && n == t.abandonedThreshold && t.onAbandoned != nil {
+		t.onAbandoned(n)
+	}
+}
+
+// release is called when a handler goroutine returns
+func (t *Timeout) release(state *atomic.Int32) {
+	if !state.CompareAndSwap(handlerRunning, handlerFinished) {
+		t.abandoned.Add(-1)
+	}
+}
+
+// Abandoned returns the number of handler goroutines still running past their deadline
+func (t *Timeout) Abandoned() int64 {
+	return t.abandoned.Load()
+}
+
+// AbandonedHighWater returns the highest number of simultaneously abandoned handler goroutines
+func (t *Timeout) AbandonedHighWater() int64 {
+	return t.abandonedHighWater.Load()
+}
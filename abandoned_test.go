@@ -0,0 +1,43 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	var crossed int64
+	tm := NewTimeout(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) {
+			<-release
+		}),
+		WithAbandonedThreshold(2, func(count int64) {
+			crossed = count
+		}),
+	)
+	r := gin.New()
+	r.GET("/", tm.Middleware())
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		r.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, int64(3), tm.Abandoned())
+	assert.Equal(t, int64(3), tm.AbandonedHighWater())
+	assert.Equal(t, int64(2), crossed)
+
+	close(release)
+	tm.Wait()
+	assert.Equal(t, int64(0), tm.Abandoned())
+	assert.Equal(t, int64(3), tm.AbandonedHighWater())
+}
@@ -0,0 +1,123 @@
+package timeout
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// alertBuckets is the number of buckets a rate alert window is split into
+	alertBuckets = 10
+	// alertMinRequests is the number of requests a window needs before its
+	// rate is trusted, so a single early timeout does not page anyone
+	alertMinRequests = 10
+)
+
+// RateAlertFunc is called when the fraction of timed out requests of a route
+// crosses the threshold, route is empty for the overall rate
+type RateAlertFunc func(route string, rate float64)
+
+type rateBucket struct {
+	start    time.Time
+	total    int64
+	timeouts int64
+}
+
+// rateWindow counts requests and timeouts over a sliding window of buckets
+type rateWindow struct {
+	buckets [alertBuckets]rateBucket
+	firing  bool
+}
+
+// add counts a request and returns the timeout rate over the window
+func (w *rateWindow) add(now time.Time, width time.Duration, timedOut bool) (float64, int64) {
+	start := now.Truncate(width)
+	b := &w.buckets[(start.UnixNano()/int64(width))%alertBuckets]
+	if !b.start.Equal(start) {
+		*b = rateBucket{start: start}
+	}
+	b.total++
+	if timedOut {
+		b.timeouts++
+	}
+	return w.rate(now, width)
+}
+
+// rate returns the timeout rate and the number of requests over the window
+func (w *rateWindow) rate(now time.Time, width time.Duration) (float64, int64) {
+	var total, timeouts int64
+	oldest := now.Truncate(width).Add(-width * (alertBuckets - 1))
+	for _, b := range w.buckets {
+		if !b.start.Before(oldest) {
+			total += b.total
+			timeouts += b.timeouts
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(timeouts) / float64(total), total
+}
+
+// rateAlert tracks timeout rates per route and overall
+type rateAlert struct {
+	mu        sync.Mutex
+	width     time.Duration
+	threshold float64
+	f         RateAlertFunc
+	total     rateWindow
+	routes    map[string]*rateWindow
+}
+
+func newRateAlert(window time.Duration, threshold float64, f RateAlertFunc) *rateAlert {
+	return &rateAlert{
+		width:     max(window/alertBuckets, time.Nanosecond),
+		threshold: threshold,
+		f:         f,
+		routes:    make(map[string]*rateWindow),
+	}
+}
+
+// observe counts a request and calls the alert function, outside the lock,
+// for each window whose rate crossed the threshold
+func (a *rateAlert) observe(route string, timedOut bool, now time.Time) {
+	type crossing struct {
+		route string
+		rate  float64
+	}
+	var fire []crossing
+
+	a.mu.Lock()
+	w, ok := a.routes[route]
+	if !ok {
+		w = &rateWindow{}
+		a.routes[route] = w
+	}
+	if rate, ok := a.check(&a.total, now, timedOut); ok {
+		fire = append(fire, crossing{"", rate})
+	}
+	if rate, ok := a.check(w, now, timedOut); ok {
+		fire = append(fire, crossing{route, rate})
+	}
+	a.mu.Unlock()
+
+	for _, c := range fire {
+		a.f(c.route, c.rate)
+	}
+}
+
+// check counts a request in w and reports whether its rate just crossed the
+// threshold, the alert is re-armed once the rate falls back below it or the
+// window has too few requests to tell
+func (a *rateAlert) check(w *rateWindow, now time.Time, timedOut bool) (float64, bool) {
+	rate, n := w.add(now, a.width, timedOut)
+	if rate < a.threshold || n < alertMinRequests {
+		w.firing = false
+		return rate, false
+	}
+	if w.firing {
+		return rate, false
+	}
+	w.firing = true
+	return rate, true
+}
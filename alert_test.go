@@ -0,0 +1,40 @@
+package timeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateAlert(t *testing.T) {
+	type alert struct {
+		route string
+		rate  float64
+	}
+	var alerts []alert
+	a := newRateAlert(10*time.Second, 0.5, func(route string, rate float64) {
+		alerts = append(alerts, alert{route, rate})
+	})
+
+	now := time.Unix(1000, 0)
+	for i := 0; i < 10; i++ {
+		a.observe("/ok", false, now)
+	}
+	assert.Empty(t, alerts)
+
+	for i := 0; i < 10; i++ {
+		a.observe("/slow", true, now)
+	}
+	assert.Equal(t, []alert{{"", 0.5}, {"/slow", 1}}, alerts)
+
+	// the requests left the window, the alert is armed again
+	now = now.Add(time.Minute)
+	for i := 0; i < 10; i++ {
+		a.observe("/ok", false, now)
+	}
+	for i := 0; i < 10; i++ {
+		a.observe("/slow", true, now)
+	}
+	assert.Len(t, alerts, 4)
+}
@@ -0,0 +1,64 @@
+package timeout
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Job is the outcome of a handler moved to the background by WithAsync
+type Job struct {
+	// ID is the job ID sent to the client in the 202 response
+	ID string
+	// Response is what the handler wrote
+	Response Partial
+	// Info describes how the handler finished
+	Info CompletionInfo
+}
+
+// JobFunc receives a background job once its handler returned, c is a copy
+// of the request context that is safe to use after the request ended
+type JobFunc func(c *gin.Context, job Job)
+
+// AcceptedBody is the body of the 202 response sent by WithAsync
+type AcceptedBody struct {
+	JobID string `json:"job_id"`
+}
+
+// newJobID returns a random job ID
+func newJobID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// runAsync answers a timed out request with 202 and a job ID and lets the
+// handler of a finish in the background, it reports false if the handler
+// output already reached the client
+func (t *Timeout) runAsync(c *gin.Context, a *attempt) bool {
+	tw, ok := a.tw.(*Writer)
+	if !ok || !tw.moveToBackground() {
+		return false
+	}
+	t.abandon(&a.state)
+
+	id := newJobID()
+	c.JSON(http.StatusAccepted, AcceptedBody{JobID: id})
+
+	cp := c.Copy()
+	go func() {
+		<-a.done
+		job := Job{
+			ID:       id,
+			Response: tw.partial(),
+			Info:     completionInfo(tw, t.clock.Now().Sub(a.start), a.handlerPanic),
+		}
+		tw.FreeBuffer()
+		t.bufPool.Put(a.buffer)
+		t.async(cp, job)
+		t.completed(cp, job.Info)
+	}()
+	return true
+}
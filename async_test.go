@@ -0,0 +1,43 @@
+package timeout
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsync(t *testing.T) {
+	jobs := make(chan Job, 1)
+	r := gin.New()
+	r.POST("/report", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(50 * time.Millisecond)
+			c.String(http.StatusCreated, "report ready")
+		}),
+		WithAsync(func(c *gin.Context, job Job) {
+			jobs <- job
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/report", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var body AcceptedBody
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.JobID, 32)
+
+	job := <-jobs
+	assert.Equal(t, body.JobID, job.ID)
+	assert.Equal(t, http.StatusCreated, job.Response.Status)
+	assert.Equal(t, "report ready", string(job.Response.Body))
+	assert.Equal(t, http.StatusCreated, job.Info.Status)
+	assert.NoError(t, job.Info.Err)
+}
@@ -0,0 +1,183 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// attempt is a single execution of the handler on its own goroutine,
+// buffered writer and copy of the context
+type attempt struct {
+	c      *gin.Context
+	cancel context.CancelFunc
+	errs   int
+	tw     BufferedWriter
+	buffer *bytes.Buffer
+	start  time.Time
+	done   chan struct{}
+	state  atomic.Int32
+
+	// panic is set when the handler panicked, before the attempt is sent
+	panic *recovered
+	// handlerPanic is the recovered value, only read after done is closed
+	handlerPanic any
+	// detached is set once the attempt lost, its result is then ignored
+	detached bool
+	// label identifies the goroutine for handlerStack, empty unless
+	// WithTimeoutStack is set
+	label string
+	// partial is the buffered response at the time the attempt was detached,
+	// only captured when a fallback is set
+	partial Partial
+	// activity receives a value when the handler wrote, nil unless
+	// WithIdleTimeout is set
+	activity chan struct{}
+}
+
+// startAttempt runs the handler on a copy of c with a fresh buffered writer
+// over w and sends the attempt to results when the handler returns or panics.
+// The request context of the copy expires at deadline unless it is zero, and
+// is canceled once the attempt is detached or done. release is called once
+// the handler goroutine is done.
+func (t *Timeout) startAttempt(c *gin.Context, w gin.ResponseWriter, deadline time.Time, results chan<- *attempt, release func()) *attempt {
+	a := &attempt{c: handlerContext(c), errs: len(c.Errors), done: make(chan struct{})}
+	var ctx context.Context
+	if deadline.IsZero() {
+		ctx, a.cancel = context.WithCancel(c.Request.Context())
+	} else {
+		ctx, a.cancel = context.WithDeadline(c.Request.Context(), deadline)
+	}
+	if t.timeoutStack {
+		// labels the handler adds with pprof.Do on its context keep this one
+		a.label = newAttemptLabel()
+		ctx = pprof.WithLabels(ctx, pprof.Labels(attemptLabel, a.label))
+	}
+	a.c.Request = c.Request.WithContext(ctx)
+	t.region(c, "buffer", func() {
+		a.buffer = t.bufPool.Get()
+		a.tw = t.writerFactory(w, a.buffer)
+		a.c.Writer = a.tw
+		a.buffer.Reset()
+		if size := t.routeBufferSize(c.FullPath()); size > 0 {
+			a.buffer.Grow(size)
+		}
+	})
+
+	if w, ok := a.tw.(*Writer); ok && w.streamReaders {
+		w.deadline = func() time.Time { return deadlineFrom(c).wall() }
+	}
+	if w, ok := a.tw.(*Writer); ok && t.idleTimeout {
+		a.activity = make(chan struct{}, 1)
+		w.onWrite = func() {
+			select {
+			case a.activity <- struct{}{}:
+			default:
+			}
+		}
+	}
+	a.start = t.clock.Now()
+	t.inFlight.Add(1)
+	go func() {
+		defer close(a.done)
+		defer a.cancel()
+		defer release()
+		defer t.release(&a.state)
+		defer t.inFlight.Add(-1)
+		defer func() {
+			if p := recover(); p != nil {
+				a.handlerPanic = p
+				a.panic = &recovered{value: p, stack: t.captureStack()}
+				results <- a
+			}
+		}()
+		if t.latency != nil {
+			<-t.clock.After(t.latency.Sample())
+		}
+		handler := func() {
+			t.region(a.c, "handler", func() {
+				t.handler(a.c)
+				// the rest of the chain runs on the copy as well, this is a
+				// no-op if the handler called c.Next itself
+				a.c.Next()
+			})
+		}
+		if a.label != "" {
+			runLabeled(ctx, handler)
+		} else {
+			handler()
+		}
+		results <- a
+	}()
+	return a
+}
+
+// handlerContext returns the copy of c an attempt runs the handler on. gin
+// reuses c once the middleware returned, while an abandoned handler may still
+// be running, so the handler never gets c itself. c.Copy drops the handler
+// chain, it is restored so a handler calling c.Next runs the rest of the
+// chain on the copy, within the budget.
+func handlerContext(c *gin.Context) *gin.Context {
+	cp := c.Copy()
+	copyChain(cp, c)
+	// the errors the handler adds must not end up in the array of c
+	cp.Errors = c.Errors[:len(c.Errors):len(c.Errors)]
+	return cp
+}
+
+// chainFields are the unexported fields of gin.Context holding the handler
+// chain and the position of the current handler in it
+var chainFields = []string{"handlers", "index"}
+
+// copyChain sets the handler chain and position of dst to those of src,
+// gin has no API to continue a chain on a copied context
+func copyChain(dst, src *gin.Context) {
+	d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for _, name := range chainFields {
+		df, sf := d.FieldByName(name), s.FieldByName(name)
+		reflect.NewAt(df.Type(), unsafe.Pointer(df.UnsafeAddr())).Elem().
+			Set(reflect.NewAt(sf.Type(), unsafe.Pointer(sf.UnsafeAddr())).Elem())
+	}
+}
+
+// mergeContext brings the Keys and Errors the handler of the winning attempt
+// set on its copy of the context back to the request context
+func mergeContext(c *gin.Context, a *attempt) {
+	for k, v := range a.c.Keys {
+		c.Set(k, v)
+	}
+	c.Errors = append(c.Errors, a.c.Errors[a.errs:]...)
+}
+
+// hedgeTimer fires when an idempotent request should start a hedged attempt,
+// it is nil when hedging is off
+func (t *Timeout) hedgeTimer(c *gin.Context, timeout time.Duration) <-chan time.Time {
+	if t.hedgeAfter <= 0 || !idempotent(c) {
+		return nil
+	}
+	return t.clock.After(time.Duration(float64(timeout) * t.hedgeAfter))
+}
+
+// shouldRetry reports whether a timed out request may run its handler again
+func (t *Timeout) shouldRetry(c *gin.Context) bool {
+	if t.retryIf != nil {
+		return t.retryIf(c)
+	}
+	return idempotent(c)
+}
+
+// idempotent reports whether requests with method may be run more than once
+func idempotent(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package timeout
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	r := gin.New()
+	r.Any("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			c.String(http.StatusOK, "ok")
+		}),
+		WithRetry(1, nil),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, int32(2), calls.Load())
+
+	// POST is not idempotent, it is not retried
+	calls.Store(0)
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHedge(t *testing.T) {
+	var calls atomic.Int32
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				time.Sleep(500 * time.Millisecond)
+				c.String(http.StatusOK, "slow")
+				return
+			}
+			c.String(http.StatusOK, "hedged")
+		}),
+		WithHedge(0.01),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	start := time.Now()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hedged", w.Body.String())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestHedgeEventStream(t *testing.T) {
+	var calls atomic.Int32
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(200*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			c.Header("Content-Type", "text/event-stream")
+			_, _ = c.Writer.WriteString("part1-")
+			c.Writer.Flush()
+			time.Sleep(50 * time.Millisecond)
+			_, _ = c.Writer.WriteString("part2\n")
+		}),
+		WithHedge(0.1),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// the hedged execution started, but only one of them reached the client
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "part1-part2\n", w.Body.String())
+}
+
+func TestRetryMergesContext(t *testing.T) {
+	var calls atomic.Int32
+	var user any
+	var errs []string
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		user, _ = c.Get("user")
+		errs = c.Errors.Errors()
+	})
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			c.Set("user", "gopher")
+			_ = c.Error(errors.New("cache miss"))
+			c.Status(http.StatusOK)
+		}),
+		WithRetry(1, nil),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gopher", user)
+	assert.Equal(t, []string{"cache miss"}, errs)
+}
+
+func TestHandlerChainRunsOnce(t *testing.T) {
+	var calls atomic.Int32
+	count := func(c *gin.Context) {
+		calls.Add(1)
+		c.String(http.StatusOK, "ok")
+	}
+
+	// the handler continues the chain itself
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+	))
+	r.GET("/", count)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, int32(1), calls.Load())
+
+	// the handler leaves the rest of the chain to the middleware
+	calls.Store(0)
+	r = gin.New()
+	r.GET("/", New(WithTimeout(time.Second), WithHandler(func(c *gin.Context) {})), count)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestChainFields(t *testing.T) {
+	// copyChain relies on these unexported fields of gin.Context
+	typ := reflect.TypeOf((*gin.Context)(nil)).Elem()
+	for _, name := range chainFields {
+		_, ok := typ.FieldByName(name)
+		assert.True(t, ok, name)
+	}
+}
@@ -0,0 +1,42 @@
+package timeout
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// bodyWatcher signals once the request body was read to the end or closed
+type bodyWatcher struct {
+	io.ReadCloser
+	once sync.Once
+	read chan struct{}
+}
+
+func (b *bodyWatcher) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *bodyWatcher) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
+
+func (b *bodyWatcher) done() {
+	b.once.Do(func() { close(b.read) })
+}
+
+// watchBody returns a channel closed once the request body was consumed, nil
+// if the request has no body
+func watchBody(r *http.Request) <-chan struct{} {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	b := &bodyWatcher{ReadCloser: r.Body, read: make(chan struct{})}
+	r.Body = b
+	return b.read
+}
@@ -0,0 +1,73 @@
+package timeout
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Portion returns a child of the request context whose deadline leaves it the
+// fraction of the remaining budget, e.g. 0.8 to keep some time to render the
+// response. Outside of the middleware the context only inherits cancellation.
+func Portion(c *gin.Context, fraction float64) (context.Context, context.CancelFunc) {
+	remaining, ok := Remaining(c)
+	if !ok {
+		return context.WithCancel(c.Request.Context())
+	}
+	return context.WithTimeout(c.Request.Context(), time.Duration(float64(remaining)*fraction))
+}
+
+// Split returns n children of the request context for a handler fanning out
+// to n backends at once. They run concurrently, so they share a common
+// deadline at the end of the remaining budget rather than a share of it. The
+// cancel function releases all of them. Outside of the middleware the
+// contexts only inherit cancellation.
+func Split(c *gin.Context, n int) ([]context.Context, context.CancelFunc) {
+	if n <= 0 {
+		return nil, func() {}
+	}
+	remaining, ok := Remaining(c)
+	deadline := time.Now().Add(remaining)
+	ctxs := make([]context.Context, n)
+	cancels := make([]context.CancelFunc, n)
+	for i := range ctxs {
+		if ok {
+			ctxs[i], cancels[i] = context.WithDeadline(c.Request.Context(), deadline)
+		} else {
+			ctxs[i], cancels[i] = context.WithCancel(c.Request.Context())
+		}
+	}
+	return ctxs, func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// Steps divides the remaining budget into n consecutive steps for handlers
+// calling backends one after the other: the i-th context expires after i+1
+// steps, so time left unused by a call carries over to the next ones. Use
+// Split for concurrent calls. The cancel function releases all of them.
+func Steps(c *gin.Context, n int) ([]context.Context, context.CancelFunc) {
+	if n <= 0 {
+		return nil, func() {}
+	}
+	remaining, ok := Remaining(c)
+	ctxs := make([]context.Context, n)
+	cancels := make([]context.CancelFunc, n)
+	now := time.Now()
+	for i := range ctxs {
+		if ok {
+			deadline := now.Add(remaining * time.Duration(i+1) / time.Duration(n))
+			ctxs[i], cancels[i] = context.WithDeadline(c.Request.Context(), deadline)
+		} else {
+			ctxs[i], cancels[i] = context.WithCancel(c.Request.Context())
+		}
+	}
+	return ctxs, func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
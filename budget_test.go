@@ -0,0 +1,85 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	var deadlines []time.Time
+	var canceled error
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			ctxs, cancelAll := Split(c, 3)
+			defer cancelAll()
+			for _, ctx := range ctxs {
+				deadline, _ := ctx.Deadline()
+				deadlines = append(deadlines, deadline)
+			}
+			cancelAll()
+			canceled = ctxs[2].Err()
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if assert.Len(t, deadlines, 3) {
+		assert.Equal(t, deadlines[0], deadlines[1])
+		assert.Equal(t, deadlines[0], deadlines[2])
+		assert.InDelta(t, time.Second, time.Until(deadlines[0]), float64(50*time.Millisecond))
+	}
+	assert.ErrorIs(t, canceled, context.Canceled)
+
+	ctxs, cancel := Split(nil, 0)
+	defer cancel()
+	assert.Empty(t, ctxs)
+}
+
+func TestPortionAndSteps(t *testing.T) {
+	var portion time.Duration
+	var shares []time.Duration
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			ctx, cancel := Portion(c, 0.5)
+			defer cancel()
+			deadline, _ := ctx.Deadline()
+			portion = time.Until(deadline)
+
+			ctxs, cancelAll := Steps(c, 4)
+			defer cancelAll()
+			for _, ctx := range ctxs {
+				deadline, _ := ctx.Deadline()
+				shares = append(shares, time.Until(deadline))
+			}
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.InDelta(t, 500*time.Millisecond, portion, float64(50*time.Millisecond))
+	if assert.Len(t, shares, 4) {
+		assert.InDelta(t, 250*time.Millisecond, shares[0], float64(50*time.Millisecond))
+		assert.InDelta(t, time.Second, shares[3], float64(50*time.Millisecond))
+	}
+
+	// outside of the middleware there is no budget to divide
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(context.Background())
+	ctx, cancel := Portion(c, 0.5)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
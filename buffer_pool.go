@@ -3,18 +3,32 @@ package timeout
 import (
 	"bytes"
 	"sync"
+	"sync/atomic"
 )
 
 // BufferPool represents a pool of buffers.
 type BufferPool struct {
-	pool sync.Pool
+	pool   sync.Pool
+	gets   atomic.Int64
+	allocs atomic.Int64
+}
+
+// PoolStats counts the use of a BufferPool
+type PoolStats struct {
+	// Gets is the number of buffers taken from the pool
+	Gets int64 `json:"gets"`
+	// Allocations is the number of Gets the pool could not serve from a
+	// recycled buffer
+	Allocations int64 `json:"allocations"`
 }
 
 // Get returns a buffer from the buffer pool.
 // If the pool is empty, a new buffer is created and returned.
 func (p *BufferPool) Get() *bytes.Buffer {
+	p.gets.Add(1)
 	buf := p.pool.Get()
 	if buf == nil {
+		p.allocs.Add(1)
 		return &bytes.Buffer{}
 	}
 	return buf.(*bytes.Buffer)
@@ -24,3 +38,16 @@ func (p *BufferPool) Get() *bytes.Buffer {
 func (p *BufferPool) Put(buf *bytes.Buffer) {
 	p.pool.Put(buf)
 }
+
+// Stats returns the usage counters of the pool
+func (p *BufferPool) Stats() PoolStats {
+	return PoolStats{Gets: p.gets.Load(), Allocations: p.allocs.Load()}
+}
+
+// routeBufferSize returns the initial buffer capacity of a route
+func (t *Timeout) routeBufferSize(fullPath string) int {
+	if n, ok := t.routeBufferSizes[fullPath]; ok {
+		return n
+	}
+	return t.bufferSize
+}
@@ -1,8 +1,12 @@
 package timeout
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,3 +18,42 @@ func TestGetBuffer(t *testing.T) {
 	buf2 := pool.Get()
 	assert.NotEqual(t, nil, buf2)
 }
+
+func TestRouteBufferSize(t *testing.T) {
+	tm := newTimeout(
+		WithBufferSize(4096),
+		WithRouteBufferSizes(map[string]int{"/export": 1 << 20}),
+	)
+	assert.Equal(t, 4096, tm.routeBufferSize("/users"))
+	assert.Equal(t, 1<<20, tm.routeBufferSize("/export"))
+
+	var capacity int
+	r := gin.New()
+	r.GET("/export", New(
+		WithRouteBufferSizes(map[string]int{"/export": 1 << 16}),
+		WithHandler(func(c *gin.Context) {
+			capacity = c.Writer.(*Writer).body.Cap()
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/export", nil)
+	r.ServeHTTP(w, req)
+	assert.GreaterOrEqual(t, capacity, 1<<16)
+}
+
+func TestBufferPoolPerMiddleware(t *testing.T) {
+	first := NewTimeout(WithTimeout(time.Second), WithHandler(emptySuccessResponse))
+	second := NewTimeout(WithTimeout(time.Second), WithHandler(emptySuccessResponse))
+	r := gin.New()
+	r.GET("/first", first.Middleware())
+	r.GET("/second", second.Middleware())
+	// building another middleware must not reset the pool of the first one
+	_ = NewTimeout(WithTimeout(time.Second)).Middleware()
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/first", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/first", nil))
+
+	assert.Equal(t, int64(2), first.Debug().BufferPool.Gets)
+	assert.Equal(t, int64(0), second.Debug().BufferPool.Gets)
+}
@@ -0,0 +1,154 @@
+package timeout
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StaleCache keeps the last successful response of each request key so it
+// can be served, stale, when a later request with the same key times out
+type StaleCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	vary       []string
+	entries    map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	Partial
+	stored time.Time
+}
+
+// NewStaleCache will return a StaleCache holding at most maxEntries
+// responses, keyed by method, request URI and the values of the vary headers.
+// maxEntries must be positive, WithStaleCache rejects the cache otherwise.
+func NewStaleCache(maxEntries int, vary ...string) *StaleCache {
+	return &StaleCache{
+		maxEntries: maxEntries,
+		vary:       vary,
+		entries:    make(map[string]cachedResponse),
+	}
+}
+
+// key identifies the responses a request may be served from
+func (s *StaleCache) key(c *gin.Context) string {
+	return requestKey(c, s.vary)
+}
+
+// requestKey identifies a request by method, request URI and the values of
+// the vary headers
+func requestKey(c *gin.Context, vary []string) string {
+	var b strings.Builder
+	b.WriteString(c.Request.Method)
+	b.WriteByte(' ')
+	b.WriteString(c.Request.URL.RequestURI())
+	for _, h := range vary {
+		b.WriteByte('\n')
+		b.WriteString(c.GetHeader(h))
+	}
+	return b.String()
+}
+
+// store keeps a successful response that may be shared, evicting an
+// arbitrary one when full
+func (s *StaleCache) store(c *gin.Context, p Partial, now time.Time) {
+	if !shareable(c, p) {
+		return
+	}
+	key := s.key(c)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.maxEntries {
+		for k := range s.entries {
+			delete(s.entries, k)
+			break
+		}
+	}
+	s.entries[key] = cachedResponse{Partial: p, stored: now}
+}
+
+// shareable reports whether a response may be served to another caller: it
+// answers a GET or HEAD, which has no side effects, and carries neither a
+// cookie nor a Cache-Control directive keeping it out of shared caches
+func shareable(c *gin.Context, p Partial) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	if len(p.Header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, v := range p.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(name, "no-store") || strings.EqualFold(name, "private") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// load returns the cached response of a request
+func (s *StaleCache) load(c *gin.Context) (cachedResponse, bool) {
+	key := s.key(c)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.entries[key]
+	return r, ok
+}
+
+// successful returns the response of an attempt that finished in time if it
+// was fully buffered, successful and complete
+func successful(tw BufferedWriter) (Partial, bool) {
+	w, ok := tw.(*Writer)
+	if !ok || w.Committed() {
+		return Partial{}, false
+	}
+	p := w.partial()
+	if p.Status == 0 {
+		p.Status = http.StatusOK
+	}
+	if !completeStatus(p.Status) {
+		return Partial{}, false
+	}
+	return p, true
+}
+
+// completeStatus reports whether status is successful and its body holds
+// the whole representation, a 206 only holds part of it
+func completeStatus(status int) bool {
+	return status >= http.StatusOK && status < http.StatusMultipleChoices && status != http.StatusPartialContent
+}
+
+// serveStale sends the cached response of a timed out request, reporting
+// false if there is none
+func (t *Timeout) serveStale(c *gin.Context) bool {
+	if t.cache == nil {
+		return false
+	}
+	r, ok := t.cache.load(c)
+	if !ok {
+		return false
+	}
+	age := t.clock.Now().Sub(r.stored) / time.Second
+	c.Header(t.staleHeader, strconv.FormatInt(int64(age), 10))
+	writePartial(c, r.Partial)
+	return true
+}
+
+// writePartial sends a stored response, which may be shared by other requests
+func writePartial(c *gin.Context, p Partial) {
+	dst := c.Writer.Header()
+	for k, vv := range p.Header {
+		dst[k] = append([]string(nil), vv...)
+	}
+	c.Status(p.Status)
+	_, _ = c.Writer.Write(p.Body)
+}
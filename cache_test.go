@@ -0,0 +1,112 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleCache(t *testing.T) {
+	var slow atomic.Bool
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if slow.Load() {
+				time.Sleep(100 * time.Millisecond)
+			}
+			c.Header("X-Lang", c.GetHeader("Accept-Language"))
+			c.String(http.StatusOK, "fresh")
+		}),
+		WithStaleCache(NewStaleCache(16, "Accept-Language"), "X-Stale-Age"),
+	))
+
+	serve := func(lang string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", lang)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("en")
+	assert.Equal(t, "fresh", w.Body.String())
+	assert.Empty(t, w.Header().Get("X-Stale-Age"))
+
+	slow.Store(true)
+	w = serve("en")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "fresh", w.Body.String())
+	assert.Equal(t, "en", w.Header().Get("X-Lang"))
+	assert.Equal(t, "0", w.Header().Get("X-Stale-Age"))
+
+	// a different vary header value has nothing cached
+	w = serve("fr")
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
+
+func TestStaleCacheShareable(t *testing.T) {
+	var slow atomic.Bool
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if slow.Load() {
+				time.Sleep(100 * time.Millisecond)
+			}
+			c.Next()
+		}),
+		WithStaleCache(NewStaleCache(16), ""),
+	))
+	r.GET("/public", func(c *gin.Context) { c.String(http.StatusOK, "public") })
+	r.GET("/session", func(c *gin.Context) {
+		c.SetCookie("session", "alice", 0, "/", "", false, true)
+		c.String(http.StatusOK, "alice")
+	})
+	r.GET("/private", func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60, Private")
+		c.String(http.StatusOK, "alice")
+	})
+	r.GET("/no-store", func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.String(http.StatusOK, "alice")
+	})
+	r.POST("/public", func(c *gin.Context) { c.String(http.StatusOK, "created") })
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	requests := [][2]string{
+		{http.MethodGet, "/public"},
+		{http.MethodGet, "/session"},
+		{http.MethodGet, "/private"},
+		{http.MethodGet, "/no-store"},
+		{http.MethodPost, "/public"},
+	}
+	for _, req := range requests {
+		assert.Equal(t, http.StatusOK, serve(req[0], req[1]).Code, req)
+	}
+
+	slow.Store(true)
+	for _, req := range requests {
+		want := http.StatusRequestTimeout
+		if req == [2]string{http.MethodGet, "/public"} {
+			want = http.StatusOK
+		}
+		assert.Equal(t, want, serve(req[0], req[1]).Code, req)
+	}
+}
+
+func TestStaleCacheSize(t *testing.T) {
+	for _, cache := range []*StaleCache{nil, NewStaleCache(0), NewStaleCache(-1)} {
+		_, err := NewE(WithHandler(emptySuccessResponse), WithStaleCache(cache, ""))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,77 @@
+package timeout
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// LatencyDistribution samples the synthetic latency injected before a handler runs
+type LatencyDistribution interface {
+	Sample() time.Duration
+}
+
+// FixedLatency always injects the same delay
+type FixedLatency time.Duration
+
+// Sample returns the fixed delay
+func (l FixedLatency) Sample() time.Duration {
+	return time.Duration(l)
+}
+
+// seededRand is a goroutine safe, seeded source for reproducible samples
+type seededRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newSeededRand(seed int64) *seededRand {
+	//nolint:gosec // latency injection does not need a secure source
+	return &seededRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *seededRand) normFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.NormFloat64()
+}
+
+func (r *seededRand) float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+type logNormalLatency struct {
+	median time.Duration
+	sigma  float64
+	rnd    *seededRand
+}
+
+// LogNormalLatency returns a lognormal distribution with the given median and
+// shape sigma, seeded so load tests are reproducible
+func LogNormalLatency(median time.Duration, sigma float64, seed int64) LatencyDistribution {
+	return &logNormalLatency{median: median, sigma: sigma, rnd: newSeededRand(seed)}
+}
+
+func (l *logNormalLatency) Sample() time.Duration {
+	return time.Duration(float64(l.median) * math.Exp(l.sigma*l.rnd.normFloat64()))
+}
+
+type paretoLatency struct {
+	scale time.Duration
+	alpha float64
+	rnd   *seededRand
+}
+
+// ParetoLatency returns a pareto distribution with minimum scale and tail index alpha,
+// seeded so load tests are reproducible
+func ParetoLatency(scale time.Duration, alpha float64, seed int64) LatencyDistribution {
+	return &paretoLatency{scale: scale, alpha: alpha, rnd: newSeededRand(seed)}
+}
+
+func (l *paretoLatency) Sample() time.Duration {
+	u := 1 - l.rnd.float64() // (0, 1]
+	return time.Duration(float64(l.scale) / math.Pow(u, 1/l.alpha))
+}
@@ -0,0 +1,46 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyDistributions(t *testing.T) {
+	assert.Equal(t, time.Second, FixedLatency(time.Second).Sample())
+
+	a := LogNormalLatency(10*time.Millisecond, 0.5, 42)
+	b := LogNormalLatency(10*time.Millisecond, 0.5, 42)
+	for i := 0; i < 10; i++ {
+		d := a.Sample()
+		assert.Equal(t, d, b.Sample())
+		assert.Greater(t, d, time.Duration(0))
+	}
+
+	p := ParetoLatency(10*time.Millisecond, 1.5, 42)
+	for i := 0; i < 10; i++ {
+		assert.GreaterOrEqual(t, p.Sample(), 10*time.Millisecond)
+	}
+}
+
+func TestInjectedLatency(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithInjectedLatency(FixedLatency(time.Millisecond)),
+		WithHandler(func(c *gin.Context) {
+			c.String(http.StatusOK, "")
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
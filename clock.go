@@ -0,0 +1,174 @@
+package timeout
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by the middleware
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time
+	After(d time.Duration) <-chan time.Time
+	// NewTimer returns a Timer that sends the current time once d elapsed
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock, unlike After it can be moved without
+// allocating a new one
+type Timer interface {
+	// C returns the channel the time is sent on when the timer expires
+	C() <-chan time.Time
+	// Reset changes the timer to expire after d, an expiry not received yet
+	// is discarded
+	Reset(d time.Duration)
+	// Stop prevents the timer from firing, it reports false if it already
+	// expired or was stopped
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+
+func (r realTimer) Reset(d time.Duration) {
+	if !r.t.Stop() {
+		// drain an expiry nobody received, before go 1.23 it would still be delivered
+		select {
+		case <-r.t.C:
+		default:
+		}
+	}
+	r.t.Reset(d)
+}
+
+func (r realTimer) Stop() bool { return r.t.Stop() }
+
+// FakeClock is a Clock that only moves when Advance is called
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFakeClock will return a FakeClock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the fake current time
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that fires once the clock is advanced past d
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, &fakeWaiter{deadline: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// NewTimer returns a Timer that fires once the clock is advanced past d
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	ch    chan time.Time
+	// waiter is the last expiry scheduled, pending until the clock passes it
+	waiter *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Reset(d time.Duration) {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.remove(t.waiter)
+	select {
+	case <-t.ch:
+	default:
+	}
+	t.waiter = nil
+	if d <= 0 {
+		t.ch <- f.now
+		return
+	}
+	t.waiter = &fakeWaiter{deadline: f.now.Add(d), ch: t.ch}
+	f.waiters = append(f.waiters, t.waiter)
+}
+
+func (t *fakeTimer) Stop() bool {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	active := f.remove(t.waiter)
+	t.waiter = nil
+	return active
+}
+
+// remove drops w from the pending waiters, it reports whether it was pending
+func (f *FakeClock) remove(w *fakeWaiter) bool {
+	for i, p := range f.waiters {
+		if p == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward and fires every expired waiter
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.deadline.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+}
+
+// Waiters returns the number of pending After calls, useful to sync tests
+func (f *FakeClock) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
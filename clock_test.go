@@ -0,0 +1,136 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+	ch := clock.After(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Len(t, ch, 0)
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-ch)
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+	timer := clock.NewTimer(time.Second)
+	assert.Equal(t, 1, clock.Waiters())
+
+	// a reset moves the pending expiry instead of adding one
+	clock.Advance(500 * time.Millisecond)
+	timer.Reset(time.Second)
+	assert.Equal(t, 1, clock.Waiters())
+	clock.Advance(500 * time.Millisecond)
+	assert.Len(t, timer.C(), 0)
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, start.Add(1500*time.Millisecond), <-timer.C())
+
+	// an expiry nobody received is discarded by a reset
+	timer.Reset(time.Second)
+	clock.Advance(time.Second)
+	timer.Reset(time.Second)
+	assert.Len(t, timer.C(), 0)
+
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestRealClockTimer(t *testing.T) {
+	timer := realClock{}.NewTimer(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	// the expiry was not received, the reset must drop it
+	timer.Reset(time.Hour)
+	assert.Len(t, timer.C(), 0)
+	assert.True(t, timer.Stop())
+}
+
+func TestWithClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	release := make(chan struct{})
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Hour),
+		WithClock(clock),
+		WithHandler(func(c *gin.Context) {
+			<-release
+		}),
+	))
+
+	go func() {
+		for clock.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		clock.Advance(time.Hour)
+	}()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	close(release)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
+
+func TestDeadlineFollowsClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var deadline time.Time
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Minute),
+		WithClock(NewFakeClock(start)),
+		WithHandler(func(c *gin.Context) {
+			deadline = requestDeadline(c)
+			c.Status(http.StatusOK)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, start.Add(time.Minute), deadline)
+}
+
+func TestFakeClockRemaining(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	var outer, remaining time.Duration
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Minute),
+		WithClock(clock),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+	))
+	r.Use(func(c *gin.Context) {
+		outer, _ = Remaining(c)
+		c.Next()
+	})
+	// the nested middleware is bounded by the deadline on the fake clock
+	r.GET("/", New(
+		WithTimeout(2*time.Minute),
+		WithHandler(func(c *gin.Context) {
+			remaining, _ = Remaining(c)
+			c.Status(http.StatusOK)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, time.Minute, outer)
+	assert.InDelta(t, time.Minute, remaining, float64(time.Second))
+}
@@ -0,0 +1,45 @@
+package timeout
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompletionInfo describes how a handler abandoned by a timeout eventually finished
+type CompletionInfo struct {
+	// Status is the status code the handler set, 200 if it set none
+	Status int
+	// Size is the number of body bytes the handler wrote, including discarded ones
+	Size int
+	// Duration is the time from handler start until it returned
+	Duration time.Duration
+	// Err is set if the handler panicked
+	Err error
+}
+
+// CompletionFunc receives the outcome of an abandoned handler, c is a copy
+// of the request context that is safe to use after the request ended
+type CompletionFunc func(c *gin.Context, info CompletionInfo)
+
+func completionInfo(tw BufferedWriter, d time.Duration, recovered any) CompletionInfo {
+	info := CompletionInfo{Duration: d}
+	if w, ok := tw.(*Writer); ok {
+		info.Status, info.Size = w.handlerResult()
+	} else {
+		info.Status, info.Size = tw.Status(), tw.Size()
+	}
+	if recovered != nil {
+		info.Err = panicError(recovered)
+	}
+	return info
+}
+
+// panicError wraps a recovered value into an error
+func panicError(recovered any) error {
+	if err, ok := recovered.(error); ok {
+		return fmt.Errorf("timeout: handler panicked: %w", err)
+	}
+	return fmt.Errorf("timeout: handler panicked: %v", recovered)
+}
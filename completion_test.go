@@ -0,0 +1,77 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionReport(t *testing.T) {
+	reports := make(chan CompletionInfo, 1)
+	var path string
+	r := gin.New()
+	r.GET("/:kind", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(5 * time.Millisecond)
+			if c.Param("kind") == "panic" {
+				panic("late")
+			}
+			c.String(http.StatusCreated, "done")
+		}),
+		WithCompletionReport(func(c *gin.Context, info CompletionInfo) {
+			path = c.Request.URL.Path
+			reports <- info
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/ok", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+
+	info := <-reports
+	assert.Equal(t, "/ok", path)
+	assert.Equal(t, http.StatusCreated, info.Status)
+	assert.Equal(t, len("done"), info.Size)
+	assert.GreaterOrEqual(t, info.Duration, 5*time.Millisecond)
+	assert.NoError(t, info.Err)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/panic", nil)
+	r.ServeHTTP(w, req)
+
+	info = <-reports
+	assert.EqualError(t, info.Err, "timeout: handler panicked: late")
+}
+
+func TestCompletionNotifier(t *testing.T) {
+	infos := make(chan CompletionInfo, 1)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(50 * time.Millisecond)
+			c.String(http.StatusOK, "late")
+		}),
+		WithAsync(func(c *gin.Context, job Job) {}),
+		WithCompletionNotifier(func(info CompletionInfo) {
+			infos <- info
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	info := <-infos
+	assert.Equal(t, http.StatusOK, info.Status)
+	assert.Equal(t, len("late"), info.Size)
+	assert.GreaterOrEqual(t, info.Duration, 50*time.Millisecond)
+}
@@ -0,0 +1,88 @@
+package timeout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var gzipPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// acceptsGzip reports whether the Accept-Encoding header value allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// gzipBody replaces body with its gzip compressed form
+func gzipBody(body *bytes.Buffer) error {
+	var out bytes.Buffer
+	zw := gzipPool.Get().(*gzip.Writer)
+	defer gzipPool.Put(zw)
+	zw.Reset(&out)
+	if _, err := zw.Write(body.Bytes()); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	body.Reset()
+	_, err := body.Write(out.Bytes())
+	return err
+}
+
+// compress gzips the buffered body when the client accepts it and the body
+// is at least the size set with WithCompression
+func (t *Timeout) compress(c *gin.Context, tw BufferedWriter) error {
+	w, ok := tw.(*Writer)
+	if !t.compression || !ok || w.Committed() {
+		return nil
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+		return nil
+	}
+	if status := w.Status(); status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return nil
+	}
+	// byte ranges refer to the uncompressed representation
+	if h.Get("Content-Range") != "" || w.Status() == http.StatusPartialContent {
+		return nil
+	}
+	if size := w.Size(); size <= 0 || size < t.compressMin {
+		return nil
+	}
+	// a body spilled to disk is sent as is
+	if ok, err := w.rewriteBody(gzipBody); !ok || err != nil {
+		return err
+	}
+	h.Set("Content-Encoding", "gzip")
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	// the compressed body is another representation, a strong ETag no longer holds
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package timeout
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	assert.True(t, acceptsGzip("gzip"))
+	assert.True(t, acceptsGzip("br, GZIP;q=0.5"))
+	assert.True(t, acceptsGzip("*"))
+	assert.False(t, acceptsGzip(""))
+	assert.False(t, acceptsGzip("br"))
+	assert.False(t, acceptsGzip("gzip;q=0"))
+}
+
+func TestWithCompression(t *testing.T) {
+	large := strings.Repeat("hello ", 100)
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithETag(),
+		WithCompression(100),
+	))
+	r.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, "W/"+bodyETag([]byte(large)), w.Header().Get("ETag"))
+	zr, err := gzip.NewReader(w.Body)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(zr)
+		assert.Equal(t, large, string(body))
+	}
+
+	for _, tc := range []struct{ path, accept string }{
+		{"/small", "gzip"},
+		{"/large", ""},
+		{"/large", "gzip;q=0"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Encoding", tc.accept)
+		r.ServeHTTP(w, req)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), tc)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+}
+
+func TestCompressionSpilledBody(t *testing.T) {
+	large := strings.Repeat("hello ", 100)
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithCompression(100),
+		WithDigest(DigestSHA256),
+		WithSpillToDisk(64, t.TempDir()),
+	))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, large) })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	r.ServeHTTP(w, req)
+
+	// the spilled body is sent as is, with its digest streamed from the file
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+	sum := sha256.Sum256([]byte(large))
+	assert.Equal(t, "sha-256="+base64.StdEncoding.EncodeToString(sum[:]), w.Header().Get("Digest"))
+}
@@ -0,0 +1,88 @@
+package timeout
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Duration is a time.Duration that is encoded as a string like "1.5s"
+// in JSON, YAML and other text based config formats
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Config holds the middleware settings in a form that can be loaded from
+// config files, zero values keep the defaults
+type Config struct {
+	Timeout           Duration            `json:"timeout" yaml:"timeout"`
+	MinTimeout        Duration            `json:"min_timeout" yaml:"min_timeout"`
+	MaxTimeout        Duration            `json:"max_timeout" yaml:"max_timeout"`
+	RouteTimeouts     map[string]Duration `json:"route_timeouts" yaml:"route_timeouts"`
+	MaxConcurrent     int                 `json:"max_concurrent" yaml:"max_concurrent"`
+	MaxConcurrentWait Duration            `json:"max_concurrent_wait" yaml:"max_concurrent_wait"`
+
+	Handler        gin.HandlerFunc `json:"-" yaml:"-"`
+	Response       gin.HandlerFunc `json:"-" yaml:"-"`
+	RejectResponse gin.HandlerFunc `json:"-" yaml:"-"`
+}
+
+// Options converts the config into the equivalent functional options
+func (cfg Config) Options() []Option {
+	var opts []Option
+	if cfg.Timeout != 0 {
+		opts = append(opts, WithTimeout(time.Duration(cfg.Timeout)))
+	}
+	if cfg.MinTimeout != 0 {
+		opts = append(opts, WithMinTimeout(time.Duration(cfg.MinTimeout)))
+	}
+	if cfg.MaxTimeout != 0 {
+		opts = append(opts, WithMaxTimeout(time.Duration(cfg.MaxTimeout)))
+	}
+	if len(cfg.RouteTimeouts) > 0 {
+		routes := make(map[string]time.Duration, len(cfg.RouteTimeouts))
+		for path, d := range cfg.RouteTimeouts {
+			routes[path] = time.Duration(d)
+		}
+		opts = append(opts, WithRouteTimeouts(routes))
+	}
+	if cfg.MaxConcurrent != 0 {
+		opts = append(opts, WithMaxConcurrent(cfg.MaxConcurrent))
+	}
+	if cfg.MaxConcurrentWait != 0 {
+		opts = append(opts, WithMaxConcurrentWait(time.Duration(cfg.MaxConcurrentWait)))
+	}
+	if cfg.Handler != nil {
+		opts = append(opts, WithHandler(cfg.Handler))
+	}
+	if cfg.Response != nil {
+		opts = append(opts, WithResponse(cfg.Response))
+	}
+	if cfg.RejectResponse != nil {
+		opts = append(opts, WithRejectResponse(cfg.RejectResponse))
+	}
+	return opts
+}
+
+// NewWithConfig is like New but takes its settings from cfg, it panics if
+// they are invalid, pass cfg.Options() to NewE to get the error instead
+func NewWithConfig(cfg Config) gin.HandlerFunc {
+	h, err := NewE(cfg.Options()...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
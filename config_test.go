@@ -0,0 +1,59 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigJSON(t *testing.T) {
+	var cfg Config
+	err := json.Unmarshal([]byte(`{
+		"timeout": "50µs",
+		"route_timeouts": {"/fast": "1s"},
+		"max_concurrent": 10
+	}`), &cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, Duration(50*time.Microsecond), cfg.Timeout)
+	assert.Equal(t, Duration(time.Second), cfg.RouteTimeouts["/fast"])
+	assert.Equal(t, 10, cfg.MaxConcurrent)
+
+	b, err := json.Marshal(Config{Timeout: Duration(time.Second)})
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"timeout":"1s"`)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout": "soon"}`), &cfg))
+}
+
+func TestNewWithConfig(t *testing.T) {
+	r := gin.New()
+	r.GET("/", NewWithConfig(Config{
+		Timeout: Duration(50 * time.Microsecond),
+		Handler: emptySuccessResponse,
+		Response: func(c *gin.Context) {
+			c.String(http.StatusGatewayTimeout, "config response")
+		},
+	}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "config response", w.Body.String())
+}
+
+func TestNewWithConfigInvalid(t *testing.T) {
+	assert.Panics(t, func() {
+		NewWithConfig(Config{Timeout: Duration(-time.Second), Handler: emptySuccessResponse})
+	})
+	assert.Panics(t, func() {
+		NewWithConfig(Config{Timeout: Duration(time.Second)})
+	})
+}
@@ -0,0 +1,162 @@
+// Package conformance provides a test suite checking that a gin.ResponseWriter
+// wrapper interoperates with timeout.Writer when both are stacked, in either order.
+package conformance
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/timeout"
+	"github.com/gin-gonic/gin"
+)
+
+// WrapFunc wraps the writer of a request, as a third party middleware would
+type WrapFunc func(w gin.ResponseWriter) gin.ResponseWriter
+
+// Run runs the conformance suite for wrap stacked outside and inside timeout.Writer
+func Run(t *testing.T, wrap WrapFunc, opts ...timeout.Option) {
+	t.Run("WrapperOutside", func(t *testing.T) {
+		run(t, func(r *gin.Engine) {
+			r.Use(wrapMiddleware(wrap))
+			r.Use(timeoutMiddleware(opts...))
+		})
+	})
+	t.Run("WrapperInside", func(t *testing.T) {
+		run(t, func(r *gin.Engine) {
+			r.Use(timeoutMiddleware(opts...))
+			r.Use(wrapMiddleware(wrap))
+		})
+	})
+}
+
+func wrapMiddleware(wrap WrapFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = wrap(c.Writer)
+		c.Next()
+	}
+}
+
+func timeoutMiddleware(opts ...timeout.Option) gin.HandlerFunc {
+	opts = append([]timeout.Option{timeout.WithTimeout(time.Second)}, opts...)
+	opts = append(opts, timeout.WithHandler(func(c *gin.Context) { c.Next() }))
+	return timeout.New(opts...)
+}
+
+// observed is what the outermost middleware sees after the chain completed
+type observed struct {
+	status  int
+	size    int
+	written bool
+}
+
+func serve(
+	setup func(r *gin.Engine), handler gin.HandlerFunc, w http.ResponseWriter,
+) observed {
+	var obs observed
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		obs = observed{status: c.Writer.Status(), size: c.Writer.Size(), written: c.Writer.Written()}
+	})
+	setup(r)
+	r.GET("/", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+	return obs
+}
+
+func run(t *testing.T, setup func(r *gin.Engine)) {
+	t.Run("StatusAndBody", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		obs := serve(setup, func(c *gin.Context) {
+			c.Header("X-Conformance", "1")
+			c.String(http.StatusCreated, "hello")
+		}, rec)
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("response status = %d, want %d", rec.Code, http.StatusCreated)
+		}
+		if got := rec.Body.String(); got != "hello" {
+			t.Errorf("response body = %q, want %q", got, "hello")
+		}
+		if got := rec.Header().Get("X-Conformance"); got != "1" {
+			t.Errorf("response header = %q, want %q", got, "1")
+		}
+		if obs.status != http.StatusCreated {
+			t.Errorf("Status() = %d, want %d", obs.status, http.StatusCreated)
+		}
+		if obs.size != len("hello") {
+			t.Errorf("Size() = %d, want %d", obs.size, len("hello"))
+		}
+		if !obs.written {
+			t.Error("Written() = false, want true")
+		}
+	})
+
+	t.Run("StatusOnly", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		obs := serve(setup, func(c *gin.Context) {
+			c.Status(http.StatusAccepted)
+		}, rec)
+
+		if rec.Code != http.StatusAccepted {
+			t.Errorf("response status = %d, want %d", rec.Code, http.StatusAccepted)
+		}
+		if obs.status != http.StatusAccepted {
+			t.Errorf("Status() = %d, want %d", obs.status, http.StatusAccepted)
+		}
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		serve(setup, func(c *gin.Context) {
+			c.String(http.StatusOK, "a")
+			c.Writer.Flush()
+			c.String(http.StatusOK, "b")
+		}, rec)
+
+		if got := rec.Body.String(); got != "ab" {
+			t.Errorf("response body = %q, want %q", got, "ab")
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("response status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("Hijack", func(t *testing.T) {
+		rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		var err error
+		serve(setup, func(c *gin.Context) {
+			var conn net.Conn
+			conn, _, err = c.Writer.Hijack()
+			if conn != nil {
+				_ = conn.Close()
+			}
+		}, rec)
+
+		if err != nil {
+			t.Errorf("Hijack() error = %v", err)
+		}
+		if !rec.hijacked {
+			t.Error("Hijack() did not reach the underlying writer")
+		}
+	})
+}
+
+// hijackRecorder is a ResponseRecorder supporting http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
@@ -0,0 +1,19 @@
+package conformance
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type embeddingWriter struct {
+	gin.ResponseWriter
+}
+
+func TestIdentity(t *testing.T) {
+	Run(t, func(w gin.ResponseWriter) gin.ResponseWriter { return w })
+}
+
+func TestEmbedding(t *testing.T) {
+	Run(t, func(w gin.ResponseWriter) gin.ResponseWriter { return &embeddingWriter{w} })
+}
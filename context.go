@@ -0,0 +1,228 @@
+package timeout
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	timedOutKey = "github.com/gin-contrib/timeout/timed-out"
+	elapsedKey  = "github.com/gin-contrib/timeout/elapsed"
+	latencyKey  = "github.com/gin-contrib/timeout/latency"
+	infoKey     = "github.com/gin-contrib/timeout/info"
+	goneKey     = "github.com/gin-contrib/timeout/client-gone"
+	idKey       = "github.com/gin-contrib/timeout/request-id"
+	deadlineKey = "github.com/gin-contrib/timeout/deadline"
+	shadowKey   = "github.com/gin-contrib/timeout/shadow"
+	progressKey = "github.com/gin-contrib/timeout/progress"
+	sharedKey   = "github.com/gin-contrib/timeout/shared"
+	originalKey = "github.com/gin-contrib/timeout/original"
+)
+
+// Phase is the part of a request a budget applies to, see WithPhaseBudgets
+type Phase string
+
+const (
+	// PhaseRead is reading the request body
+	PhaseRead Phase = "read"
+	// PhaseProcess is running the handler
+	PhaseProcess Phase = "process"
+	// PhaseWrite is sending the buffered response to the client
+	PhaseWrite Phase = "write"
+)
+
+// Info describes a timeout to the response handler set with WithResponseFunc
+type Info struct {
+	// Phase is the phase whose budget ran out
+	Phase Phase
+	// Timeout is the budget that was applied to the request
+	Timeout time.Duration
+	// Status is the status code of the built-in responses, see WithStatus
+	Status int
+	// Elapsed is how long the handler ran before the timeout fired
+	Elapsed time.Duration
+	// FullPath is the matched route, empty if none matched
+	FullPath string
+	// ClientGone is true if the client had already disconnected
+	ClientGone bool
+	// RequestID is the correlation ID, empty unless WithRequestID is set
+	RequestID string
+	// Progress is the latest progress reported by the handler, if any
+	Progress *ProgressEntry
+	// Stages is the time spent in each stage marked with Annotate
+	Stages []StageTiming
+	// Stack is where the handler was when the timeout fired, only captured
+	// with WithTimeoutStack
+	Stack []byte
+}
+
+// infoFrom returns the Info stored on the context by the timeout path
+func infoFrom(c *gin.Context) Info {
+	if v, ok := c.Get(infoKey); ok {
+		if info, ok := v.(Info); ok {
+			return info
+		}
+	}
+	return Info{FullPath: c.FullPath(), RequestID: RequestID(c)}
+}
+
+// TimeoutInfo returns the details of the timeout of the current request, ok is
+// false if it did not time out
+func TimeoutInfo(c *gin.Context) (Info, bool) {
+	v, ok := c.Get(infoKey)
+	if !ok {
+		return Info{}, false
+	}
+	info, ok := v.(Info)
+	return info, ok
+}
+
+// timeoutInfo describes the timeout of the current request, a body that
+// was read too slowly is always answered with 408
+func (t *Timeout) timeoutInfo(c *gin.Context, phase Phase, timeout time.Duration) Info {
+	info := Info{
+		Phase:      phase,
+		Timeout:    timeout,
+		Status:     http.StatusRequestTimeout,
+		Elapsed:    Elapsed(c),
+		FullPath:   c.FullPath(),
+		ClientGone: c.Request.Context().Err() != nil,
+		RequestID:  RequestID(c),
+	}
+	if t.status != 0 && phase != PhaseRead {
+		info.Status = t.status
+	}
+	if p, ok := LastProgress(c); ok {
+		info.Progress = &p
+	}
+	if tr := trackerFrom(c); tr != nil {
+		info.Stages = tr.freeze()
+	}
+	return info
+}
+
+// RequestID returns the correlation ID found by WithRequestID, empty if none
+func RequestID(c *gin.Context) string {
+	return c.GetString(idKey)
+}
+
+// IsTimedOut reports whether the request handled by the timeout middleware timed out
+func IsTimedOut(c *gin.Context) bool {
+	return c.GetBool(timedOutKey)
+}
+
+// IsClientGone reports whether the request was abandoned because the client
+// disconnected, only set when WithClientGone is used
+func IsClientGone(c *gin.Context) bool {
+	return c.GetBool(goneKey)
+}
+
+// WouldHaveTimedOut reports whether the request exceeded its timeout while
+// the timeout was not enforced, see WithDryRun
+func WouldHaveTimedOut(c *gin.Context) bool {
+	return c.GetBool(shadowKey)
+}
+
+// Original returns the request context a retried or hedged handler execution
+// was copied from, c itself otherwise. The original context is in use by the
+// middleware and possibly by other executions at the same time, only read
+// from it, e.g. to reach the engine, and do not write the response through it.
+func Original(c *gin.Context) *gin.Context {
+	if v, ok := c.Get(originalKey); ok {
+		if orig, ok := v.(*gin.Context); ok {
+			return orig
+		}
+	}
+	return c
+}
+
+// Elapsed returns how long the wrapped handler ran, measured until it finished
+// or until the timeout fired, zero if the middleware did not run the handler
+func Elapsed(c *gin.Context) time.Duration {
+	return c.GetDuration(elapsedKey)
+}
+
+// Remaining returns the budget left before the middleware times the request
+// out, zero once it expired, ok is false outside of the middleware. Use it to
+// size sub-deadlines, e.g. context.WithTimeout around a database call.
+func Remaining(c *gin.Context) (d time.Duration, ok bool) {
+	remaining, ok := deadlineFrom(c).remaining()
+	return max(remaining, 0), ok
+}
+
+// requestDeadline returns when the middleware times the request out on its
+// clock, zero if the deadline is not known yet
+func requestDeadline(c *gin.Context) time.Time {
+	return deadlineFrom(c).get()
+}
+
+// deadlineFrom returns the deadline stored on the context, nil if none
+func deadlineFrom(c *gin.Context) *sharedDeadline {
+	v, _ := c.Get(deadlineKey)
+	d, _ := v.(*sharedDeadline)
+	return d
+}
+
+// sharedDeadline holds when the request times out, it is shared by the
+// copies of the context the handler runs on so a restarted budget is visible
+// to all of them. The deadline is on the clock of the middleware, compare it
+// through remaining or convert it with wall.
+type sharedDeadline struct {
+	mu    sync.Mutex
+	at    time.Time
+	clock Clock
+}
+
+// newDeadline stores a deadline on the clock on the context
+func newDeadline(c *gin.Context, clock Clock) *sharedDeadline {
+	d := &sharedDeadline{clock: clock}
+	c.Set(deadlineKey, d)
+	return d
+}
+
+// set moves the deadline
+func (d *sharedDeadline) set(at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.at = at
+}
+
+// get returns the deadline, zero if it is not known yet or d is nil
+func (d *sharedDeadline) get() time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.at
+}
+
+// remaining returns the time left until the deadline on its clock, ok is
+// false if the deadline is not known yet or d is nil
+func (d *sharedDeadline) remaining() (time.Duration, bool) {
+	at := d.get()
+	if at.IsZero() {
+		return 0, false
+	}
+	return at.Sub(d.clock.Now()), true
+}
+
+// wall returns the deadline on the wall clock, as needed by contexts and
+// connection deadlines, zero if it is not known yet or d is nil
+func (d *sharedDeadline) wall() time.Time {
+	remaining, ok := d.remaining()
+	if !ok {
+		return time.Time{}
+	}
+	return time.Now().Add(remaining)
+}
+
+// Latency returns the time from request arrival until the handler finished or
+// the timeout fired. Unlike Elapsed it includes time spent queueing for
+// admission or a concurrency slot, so it does not hide coordinated omission.
+func Latency(c *gin.Context) time.Duration {
+	return c.GetDuration(latencyKey)
+}
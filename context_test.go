@@ -0,0 +1,203 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTimedOutAndElapsed(t *testing.T) {
+	var timedOut bool
+	var elapsed time.Duration
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		timedOut = IsTimedOut(c)
+		elapsed = Elapsed(c)
+	})
+	r.GET("/slow", New(WithTimeout(50*time.Microsecond), WithHandler(emptySuccessResponse)))
+	r.GET("/fast", New(WithTimeout(1*time.Second), WithHandler(emptySuccessResponse)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/slow", nil)
+	r.ServeHTTP(w, req)
+	assert.True(t, timedOut)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Microsecond)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/fast", nil)
+	r.ServeHTTP(w, req)
+	assert.False(t, timedOut)
+	assert.GreaterOrEqual(t, elapsed, 200*time.Microsecond)
+}
+
+func TestLatency(t *testing.T) {
+	var elapsed, latency time.Duration
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		elapsed = Elapsed(c)
+		latency = Latency(c)
+	})
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithHandler(emptySuccessResponse2),
+		WithStartTime(func(c *gin.Context) time.Time {
+			return time.Now().Add(-time.Second)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Less(t, elapsed, time.Second)
+	assert.GreaterOrEqual(t, latency, time.Second)
+}
+
+func TestResponseFunc(t *testing.T) {
+	var info Info
+	r := gin.New()
+	r.GET("/users/:id", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(emptySuccessResponse),
+		WithResponseFunc(func(c *gin.Context, i Info) {
+			info = i
+			c.String(http.StatusGatewayTimeout, "timed out after %s", i.Timeout)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/users/1", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "timed out after 50µs", w.Body.String())
+	assert.Equal(t, 50*time.Microsecond, info.Timeout)
+	assert.Equal(t, "/users/:id", info.FullPath)
+	assert.GreaterOrEqual(t, info.Elapsed, 50*time.Microsecond)
+	assert.False(t, info.ClientGone)
+}
+
+func TestRequestID(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(time.Millisecond)
+		}),
+		WithRequestID("X-Request-ID"),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc123")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, "Request Timeout (request id abc123)", w.Body.String())
+}
+
+func TestRemaining(t *testing.T) {
+	var remaining time.Duration
+	var ok bool
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			remaining, ok = Remaining(c)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.True(t, ok)
+	assert.Greater(t, remaining, 500*time.Millisecond)
+	assert.LessOrEqual(t, remaining, time.Second)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, ok = Remaining(c)
+	assert.False(t, ok)
+}
+
+func TestOriginal(t *testing.T) {
+	var calls atomic.Int32
+	var orig, self *gin.Context
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		self = c
+		c.Next()
+	}, New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+				return
+			}
+			orig = Original(c)
+			assert.NotSame(t, orig, c)
+			c.Status(http.StatusOK)
+		}),
+		WithRetry(1, nil),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Same(t, self, orig)
+}
+
+func TestRequestContextDeadline(t *testing.T) {
+	done := make(chan error, 1)
+	var deadline time.Time
+	var ok bool
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			deadline, ok = c.Request.Context().Deadline()
+			<-c.Request.Context().Done()
+			done <- c.Request.Context().Err()
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	// the deadline and the cancellation on timeout race, both end the context
+	assert.Error(t, <-done)
+	assert.True(t, ok)
+	assert.WithinDuration(t, start.Add(50*time.Millisecond), deadline, 20*time.Millisecond)
+}
+
+func TestRequestContextCanceledOnTimeout(t *testing.T) {
+	done := make(chan error, 1)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithIdleTimeout(),
+		WithHandler(func(c *gin.Context) {
+			// the budget restarts on writes, there is no fixed deadline
+			_, ok := c.Request.Context().Deadline()
+			assert.False(t, ok)
+			<-c.Request.Context().Done()
+			done <- c.Request.Context().Err()
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,208 @@
+package timeout
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugInfo is the effective configuration and state of a middleware, as
+// served by DebugHandler
+type DebugInfo struct {
+	Timeout    Duration `json:"timeout"`
+	MinTimeout Duration `json:"min_timeout,omitempty"`
+	MaxTimeout Duration `json:"max_timeout,omitempty"`
+	// Routes holds the timeouts set per full path and per route pattern
+	Routes map[string]Duration `json:"routes,omitempty"`
+	// Tiers holds the timeouts set per caller tier
+	Tiers map[string]Duration `json:"tiers,omitempty"`
+	// Modes lists the optional behaviors that are enabled, sorted
+	Modes            []string  `json:"modes"`
+	Retries          int       `json:"retries,omitempty"`
+	HedgeAfter       float64   `json:"hedge_after,omitempty"`
+	EnforcementRatio float64   `json:"enforcement_ratio"`
+	MaxConcurrent    int       `json:"max_concurrent,omitempty"`
+	BufferSize       int       `json:"buffer_size,omitempty"`
+	BufferPool       PoolStats `json:"buffer_pool"`
+
+	InFlight           int64 `json:"in_flight"`
+	Waiting            int64 `json:"waiting"`
+	Abandoned          int64 `json:"abandoned"`
+	AbandonedHighWater int64 `json:"abandoned_high_water"`
+}
+
+// Debug returns the effective configuration and state of the middleware
+func (t *Timeout) Debug() DebugInfo {
+	load := t.load()
+	info := DebugInfo{
+		Timeout:            Duration(t.Timeout()),
+		MinTimeout:         Duration(t.minTimeout),
+		MaxTimeout:         Duration(t.maxTimeout),
+		Modes:              t.modes(),
+		Retries:            t.retries,
+		HedgeAfter:         t.hedgeAfter,
+		EnforcementRatio:   t.enforceRatio,
+		MaxConcurrent:      t.maxConcurrent,
+		BufferSize:         t.bufferSize,
+		InFlight:           load.InFlight,
+		Waiting:            load.Waiting,
+		Abandoned:          load.Abandoned,
+		AbandonedHighWater: t.AbandonedHighWater(),
+		BufferPool:         t.bufPool.Stats(),
+	}
+	if len(t.routeTimeouts) > 0 || len(t.routePatterns) > 0 {
+		info.Routes = make(map[string]Duration, len(t.routeTimeouts)+len(t.routePatterns))
+		for _, p := range t.routePatterns {
+			pattern := p.prefix
+			if !p.exact {
+				pattern += "*"
+			}
+			info.Routes[pattern] = Duration(p.timeout)
+		}
+		for path, d := range t.routeTimeouts {
+			info.Routes[path] = Duration(d)
+		}
+	}
+	if len(t.tiers) > 0 {
+		info.Tiers = make(map[string]Duration, len(t.tiers))
+		for tier, d := range t.tiers {
+			info.Tiers[tier] = Duration(d)
+		}
+	}
+	return info
+}
+
+// modeFields maps the fields of Timeout that options set to the mode Debug
+// reports while they differ from their default, several fields may belong to
+// one mode. Every field is either listed here or in debugSkipped.
+var modeFields = map[string]string{
+	"status":             "status",
+	"routeBufferSizes":   "route_buffer_sizes",
+	"passthrough":        "passthrough",
+	"streamAfter":        "stream_after",
+	"writeThrough":       "write_through",
+	"streamReaders":      "reader_streaming",
+	"flushInterval":      "flush_interval",
+	"idleTimeout":        "idle_timeout",
+	"writerClosedErr":    "writer_closed_error",
+	"spillThreshold":     "spill",
+	"spillDir":           "spill",
+	"flushDeadline":      "flush_deadline",
+	"readTimeout":        "read_timeout",
+	"timeoutHeader":      "timeout_header",
+	"policySource":       "policy_source",
+	"skipPaths":          "skip_paths",
+	"staticFast":         "static_fast_path",
+	"unbuffered":         "static_fast_path",
+	"tierFunc":           "tier_func",
+	"retryIf":            "retry_if",
+	"sharedKeys":         "shared_keys",
+	"maxConcurrentWait":  "max_concurrent_wait",
+	"rejectResponse":     "reject_response",
+	"admission":          "admission",
+	"clock":              "clock",
+	"latency":            "latency_injection",
+	"dumpRate":           "goroutine_dump",
+	"dump":               "goroutine_dump",
+	"timeoutStack":       "timeout_stack",
+	"trace":              "trace",
+	"startTime":          "start_time",
+	"panicResponses":     "panic_responses",
+	"panicHandler":       "panic_handler",
+	"stackDisabled":      "stack_disabled",
+	"stackMaxBytes":      "stack_limit",
+	"stackMaxFrames":     "stack_limit",
+	"completion":         "completion",
+	"notifier":           "completion_notifier",
+	"preservedHeaders":   "preserved_headers",
+	"stripHopByHop":      "strip_headers",
+	"strippedHeaders":    "strip_headers",
+	"onLateWrite":        "late_write",
+	"slowThreshold":      "slow_threshold",
+	"slow":               "slow_threshold",
+	"clientGone":         "client_gone",
+	"fallback":           "fallback",
+	"cache":              "stale_cache",
+	"staleHeader":        "stale_cache",
+	"flights":            "coalesce",
+	"async":              "async",
+	"serverTiming":       "server_timing",
+	"etag":               "etag",
+	"conditional":        "not_modified",
+	"compression":        "compression",
+	"compressMin":        "compression",
+	"interceptor":        "flush_interceptor",
+	"digest":             "digest",
+	"durationHeader":     "duration_header",
+	"dryRun":             "dry_run",
+	"startAfterBody":     "start_after_body",
+	"hooks":              "hooks",
+	"errorReporter":      "error_reporter",
+	"requestIDKey":       "request_id",
+	"recorder":           "recorder",
+	"traceID":            "trace_id",
+	"expvarPrefix":       "expvar",
+	"expvars":            "expvar",
+	"stats":              "stats",
+	"recent":             "recent_timeouts",
+	"abandonedThreshold": "abandoned_threshold",
+	"onAbandoned":        "abandoned_threshold",
+	"alert":              "rate_alert",
+	"spikes":             "spike_profile",
+	"profiler":           "spike_profile",
+	"readiness":          "readiness",
+}
+
+// debugSkipped are the fields of Timeout that are not modes: reported in
+// their own DebugInfo field, always set, or holding state
+var debugSkipped = []string{
+	"timeout", "handler", "response", "writerFactory", "bufPool", "bufferSize",
+	"routeTimeouts", "routePatterns", "minTimeout", "maxTimeout",
+	"policyCache", "policyOnce", "tiers", "retries", "hedgeAfter",
+	"maxConcurrent", "enforceRatio", "optionErr", "nestedOnce",
+	"inFlight", "waiting", "abandoned", "abandonedHighWater",
+	"trackMu", "running", "idle", "closing",
+}
+
+// modes lists the optional behaviors enabled on the middleware
+func (t *Timeout) modes() []string {
+	v := reflect.ValueOf(t).Elem()
+	enabled := make(map[string]bool)
+	for field, mode := range modeFields {
+		if !enabled[mode] && t.modeSet(v, field) {
+			enabled[mode] = true
+		}
+	}
+	modes := make([]string, 0, len(enabled))
+	for mode := range enabled {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+// modeSet reports whether an option changed field from its default
+func (t *Timeout) modeSet(v reflect.Value, field string) bool {
+	switch field {
+	case "passthrough":
+		return !slices.Equal(t.passthrough, defaultPassthrough)
+	case "rejectResponse":
+		return t.rejectResponse == nil ||
+			reflect.ValueOf(t.rejectResponse).Pointer() != reflect.ValueOf(defaultRejectResponse).Pointer()
+	case "clock":
+		_, ok := t.clock.(realClock)
+		return !ok
+	}
+	return !v.FieldByName(field).IsZero()
+}
+
+// DebugHandler returns a handler serving the effective configuration and
+// state of t as JSON, meant to be mounted on an internal debug route
+func DebugHandler(t *Timeout) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, t.Debug())
+	}
+}
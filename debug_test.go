@@ -0,0 +1,88 @@
+package timeout
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandler(t *testing.T) {
+	tm := NewTimeout(
+		WithTimeout(time.Second),
+		WithRouteTimeouts(map[string]time.Duration{"/export": time.Minute}),
+		WithRoutePatterns(map[string]time.Duration{"/api/*": 2 * time.Second}),
+		WithTiers(map[string]time.Duration{"premium": 5 * time.Second}),
+		WithDryRun(true),
+		WithTrace(),
+		WithHandler(emptySuccessResponse),
+	)
+	r := gin.New()
+	r.GET("/", tm.Middleware())
+	r.GET("/debug/timeout", DebugHandler(tm))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/timeout", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"timeout":"1s"`)
+
+	var info DebugInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, Duration(time.Second), info.Timeout)
+	assert.Equal(t, map[string]Duration{
+		"/export": Duration(time.Minute),
+		"/api/*":  Duration(2 * time.Second),
+	}, info.Routes)
+	assert.Equal(t, map[string]Duration{"premium": Duration(5 * time.Second)}, info.Tiers)
+	assert.Equal(t, []string{"dry_run", "trace"}, info.Modes)
+	assert.Equal(t, int64(1), info.BufferPool.Gets)
+}
+
+func TestDebugModesCoverFields(t *testing.T) {
+	// a new option field must be reported as a mode or skipped on purpose
+	skipped := make(map[string]bool, len(debugSkipped))
+	for _, name := range debugSkipped {
+		skipped[name] = true
+	}
+	typ := reflect.TypeOf(Timeout{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		_, mode := modeFields[name]
+		assert.True(t, mode != skipped[name], "field %s must be in exactly one of modeFields and debugSkipped", name)
+	}
+	for name := range modeFields {
+		_, ok := typ.FieldByName(name)
+		assert.True(t, ok, name)
+	}
+}
+
+func TestDebugModes(t *testing.T) {
+	tm := NewTimeout(WithHandler(emptySuccessResponse))
+	assert.Empty(t, tm.Debug().Modes)
+
+	tm = NewTimeout(
+		WithHandler(emptySuccessResponse),
+		WithIdleTimeout(),
+		WithFlushInterval(time.Second),
+		WithStreamAfter(1024),
+		WithSpillToDisk(1024, ""),
+		WithCompression(100),
+		WithETag(),
+		WithDigest(DigestSHA256),
+		WithStripHeaders(),
+		WithReaderStreaming(),
+		WithStaticFastPath(nil),
+		WithPassthroughContentTypes("application/x-ndjson"),
+		WithClock(NewFakeClock(time.Unix(0, 0))),
+	)
+	assert.Equal(t, []string{
+		"clock", "compression", "digest", "etag", "flush_interval", "idle_timeout",
+		"passthrough", "reader_streaming", "spill", "static_fast_path", "stream_after", "strip_headers",
+	}, tm.Debug().Modes)
+}
@@ -0,0 +1,46 @@
+package timeout
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// package level defaults inherited by middlewares created afterwards
+var defaults = struct {
+	sync.RWMutex
+	timeout    time.Duration
+	response   gin.HandlerFunc
+	bufferSize int
+}{
+	timeout:  defaultTimeout,
+	response: defaultResponse,
+}
+
+// SetDefaultTimeout changes the timeout used by middlewares created afterwards
+// without WithTimeout, call it once at startup
+func SetDefaultTimeout(timeout time.Duration) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	defaults.timeout = timeout
+}
+
+// SetDefaultResponse changes the timeout response used by middlewares created
+// afterwards without WithResponse, nil restores the built-in response
+func SetDefaultResponse(h gin.HandlerFunc) {
+	if h == nil {
+		h = defaultResponse
+	}
+	defaults.Lock()
+	defer defaults.Unlock()
+	defaults.response = h
+}
+
+// SetDefaultBufferSize changes the initial capacity of response buffers used by
+// middlewares created afterwards, zero lets buffers grow on demand
+func SetDefaultBufferSize(n int) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	defaults.bufferSize = n
+}
@@ -0,0 +1,40 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageDefaults(t *testing.T) {
+	SetDefaultTimeout(50 * time.Microsecond)
+	SetDefaultResponse(testResponse)
+	SetDefaultBufferSize(1024)
+	defer func() {
+		SetDefaultTimeout(defaultTimeout)
+		SetDefaultResponse(nil)
+		SetDefaultBufferSize(0)
+	}()
+
+	tm := NewTimeout(WithHandler(emptySuccessResponse))
+	assert.Equal(t, 50*time.Microsecond, tm.Timeout())
+	assert.Equal(t, 1024, tm.bufferSize)
+
+	r := gin.New()
+	r.GET("/", tm.Middleware())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, "test response", w.Body.String())
+
+	SetDefaultResponse(nil)
+	assert.NotNil(t, NewTimeout().Response())
+}
@@ -0,0 +1,106 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DumpFunc receives a full goroutine dump captured when a request timed out
+type DumpFunc func(c *gin.Context, dump []byte)
+
+// sampled reports whether an event should be sampled at the given rate
+func sampled(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	//nolint:gosec // sampling does not need a secure source
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// attemptLabel is the pprof label identifying the goroutine of an attempt
+const attemptLabel = "gin-timeout-attempt"
+
+var attemptIDs atomic.Int64
+
+// newAttemptLabel returns a label value unique to an attempt
+func newAttemptLabel() string {
+	return strconv.FormatInt(attemptIDs.Add(1), 10)
+}
+
+// runLabeled runs f with the labels of ctx, which hold the attempt label, so
+// its goroutine can be found by handlerStack
+func runLabeled(ctx context.Context, f func()) {
+	defer pprof.SetGoroutineLabels(context.Background())
+	pprof.SetGoroutineLabels(ctx)
+	f()
+}
+
+// handlerStack returns the stack of the goroutine labeled id, nil if it is gone
+func handlerStack(id string) []byte {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil
+	}
+	for _, record := range bytes.Split(buf.Bytes(), []byte("\n\n")) {
+		if recordLabels(record)[attemptLabel] == id {
+			return record
+		}
+	}
+	return nil
+}
+
+// recordLabels returns the pprof labels of a goroutine record, which may
+// hold labels set by the handler next to the attempt label
+func recordLabels(record []byte) map[string]string {
+	for _, line := range bytes.Split(record, []byte("\n")) {
+		if rest, ok := bytes.CutPrefix(line, []byte("# labels: {")); ok {
+			return parseLabels(string(rest))
+		}
+	}
+	return nil
+}
+
+// parseLabels parses a label set written as {"key":"value", ...}, without
+// its opening brace
+func parseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, ", ")
+		if s == "" || s[0] == '}' {
+			return labels
+		}
+		k, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return labels
+		}
+		s, _ = strings.CutPrefix(s[len(k):], ":")
+		v, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return labels
+		}
+		s = s[len(v):]
+		key, _ := strconv.Unquote(k)
+		value, _ := strconv.Unquote(v)
+		labels[key] = value
+	}
+}
+
+// goroutineDump returns the stacks of all goroutines
+func goroutineDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,101 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoroutineDump(t *testing.T) {
+	assert.False(t, sampled(0))
+	assert.True(t, sampled(1))
+
+	var dump []byte
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(emptySuccessResponse),
+		WithGoroutineDump(1, func(c *gin.Context, d []byte) {
+			dump = d
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Contains(t, string(dump), "goroutine ")
+	assert.Contains(t, string(dump), "TestGoroutineDump")
+}
+
+func blockedInHandler(release chan struct{}) {
+	<-release
+}
+
+func TestTimeoutStack(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var stack []byte
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			blockedInHandler(release)
+		}),
+		WithTimeoutStack(),
+		WithHooks(Hooks{OnTimeout: func(c *gin.Context) {
+			info, _ := TimeoutInfo(c)
+			stack = info.Stack
+		}}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Contains(t, string(stack), "blockedInHandler")
+}
+
+func TestTimeoutStackWithLabels(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var stack []byte
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			// labels set by the handler join the attempt label
+			pprof.Do(c.Request.Context(), pprof.Labels("route", `/"users"`), func(context.Context) {
+				blockedInHandler(release)
+			})
+		}),
+		WithTimeoutStack(),
+		WithHooks(Hooks{OnTimeout: func(c *gin.Context) {
+			info, _ := TimeoutInfo(c)
+			stack = info.Stack
+		}}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Contains(t, string(stack), "blockedInHandler")
+}
+
+func TestParseLabels(t *testing.T) {
+	assert.Equal(t, map[string]string{"a": "1", attemptLabel: "7", "q": `x"y`},
+		parseLabels(`"a":"1", "gin-timeout-attempt":"7", "q":"x\"y"}`))
+	assert.Empty(t, parseLabels(`}`))
+	assert.Equal(t, map[string]string{"a": "1"}, parseLabels(`"a":"1", broken}`))
+}
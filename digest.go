@@ -0,0 +1,54 @@
+package timeout
+
+import (
+	"crypto/md5" //nolint:gosec // Content-MD5 is an integrity check, not a security one
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+)
+
+// DigestAlgorithm selects the integrity header set by WithDigest
+type DigestAlgorithm int
+
+const (
+	// DigestSHA256 sets "Digest: sha-256=<base64>"
+	DigestSHA256 DigestAlgorithm = iota + 1
+	// DigestMD5 sets "Content-MD5: <base64>"
+	DigestMD5
+)
+
+// header returns the header name of the algorithm and a new hash for it
+func (a DigestAlgorithm) header() (string, hash.Hash) {
+	if a == DigestMD5 {
+		return "Content-MD5", md5.New() //nolint:gosec // Content-MD5 is defined as MD5
+	}
+	return "Digest", sha256.New()
+}
+
+// setDigest sets the integrity header over the body as sent, after any
+// compression, unless the handler set it already
+func (t *Timeout) setDigest(tw BufferedWriter) error {
+	w, ok := tw.(*Writer)
+	// the digest of a 206 would cover the range, not the representation
+	if t.digest == 0 || !ok || w.Committed() || w.Status() == http.StatusPartialContent {
+		return nil
+	}
+	name, h := t.digest.header()
+	if w.Header().Get(name) != "" {
+		return nil
+	}
+	if ok, err := w.readBody(func(r io.Reader) error {
+		_, err := io.Copy(h, r)
+		return err
+	}); !ok || err != nil {
+		return err
+	}
+	value := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	if t.digest == DigestSHA256 {
+		value = "sha-256=" + value
+	}
+	w.Header().Set(name, value)
+	return nil
+}
@@ -0,0 +1,35 @@
+package timeout
+
+import (
+	"crypto/md5" //nolint:gosec // checking Content-MD5
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDigest(t *testing.T) {
+	sha := sha256.Sum256([]byte("hello"))
+	sum := md5.Sum([]byte("hello")) //nolint:gosec // checking Content-MD5
+	for alg, header := range map[DigestAlgorithm][2]string{
+		DigestSHA256: {"Digest", "sha-256=" + base64.StdEncoding.EncodeToString(sha[:])},
+		DigestMD5:    {"Content-MD5", base64.StdEncoding.EncodeToString(sum[:])},
+	} {
+		r := gin.New()
+		r.GET("/", New(
+			WithTimeout(time.Second),
+			WithHandler(func(c *gin.Context) { c.String(http.StatusOK, "hello") }),
+			WithDigest(alg),
+		))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, header[1], w.Header().Get(header[0]))
+	}
+}
@@ -0,0 +1,25 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTimeout is attached to gin.Context.Errors when the handler exceeds its timeout,
+// it wraps context.DeadlineExceeded so errors.Is works against both.
+var ErrTimeout = fmt.Errorf("timeout: handler exceeded its deadline: %w", context.DeadlineExceeded)
+
+// ErrClientGone is attached to gin.Context.Errors when the client disconnects
+// before the handler finishes and WithClientGone is set, it wraps context.Canceled.
+var ErrClientGone = fmt.Errorf("timeout: client disconnected: %w", context.Canceled)
+
+// ErrWriterClosed is returned by writes made after the timeout when
+// WithWriterClosedError is set.
+var ErrWriterClosed = errors.New("timeout: write after the handler timed out")
+
+// ErrNotReady is wrapped by the error Timeout.Ready returns when the instance
+// should be taken out of rotation.
+var ErrNotReady = errors.New("timeout: not ready")
+
+var errNilOption = errors.New("timeout Option not be nil")
@@ -0,0 +1,75 @@
+package timeout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyETag returns a strong ETag for body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return formatETag(sum[:])
+}
+
+// formatETag formats the SHA-256 sum of a body as a strong ETag
+func formatETag(sum []byte) string {
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setETag sets an ETag computed from the buffered body on a successful GET or
+// HEAD response that has none, an ETag set by the handler is kept
+func (t *Timeout) setETag(c *gin.Context, tw BufferedWriter) {
+	if !t.etag || c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return
+	}
+	if tw.Header().Get("ETag") != "" {
+		return
+	}
+	w, ok := tw.(*Writer)
+	if !ok || w.Committed() || !completeStatus(w.Status()) {
+		return
+	}
+	// hash the body where it is, a spilled one is streamed from its file
+	h := sha256.New()
+	if ok, err := w.readBody(func(r io.Reader) error {
+		_, err := io.Copy(h, r)
+		return err
+	}); ok && err == nil {
+		tw.Header().Set("ETag", formatETag(h.Sum(nil)))
+	}
+}
+
+// notModifiedHeaders are the headers of the full response kept on a 304
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Vary"}
+
+// etagMatch reports whether the If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 asks for
+func etagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the buffered response is a 200 whose ETag
+// matches the If-None-Match header of a GET or HEAD request
+func (t *Timeout) notModified(c *gin.Context, tw BufferedWriter) bool {
+	if !t.conditional || c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	etag := tw.Header().Get("ETag")
+	if ifNoneMatch == "" || etag == "" || tw.Status() != http.StatusOK {
+		return false
+	}
+	return etagMatch(ifNoneMatch, etag)
+}
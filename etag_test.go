@@ -0,0 +1,103 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithETag(t *testing.T) {
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithETag(),
+	))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+	r.GET("/tagged", func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		c.String(http.StatusOK, "hello")
+	})
+	r.GET("/missing", func(c *gin.Context) { c.String(http.StatusNotFound, "hello") })
+	r.POST("/", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+
+	for _, tc := range []struct {
+		method, path, etag string
+	}{
+		{http.MethodGet, "/", bodyETag([]byte("hello"))},
+		{http.MethodGet, "/tagged", `"v1"`},
+		{http.MethodGet, "/missing", ""},
+		{http.MethodPost, "/", ""},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.etag, w.Header().Get("ETag"), tc.method+" "+tc.path)
+		assert.Equal(t, "hello", w.Body.String())
+	}
+	assert.Len(t, bodyETag(nil), 34)
+}
+
+func TestETagSpilledBody(t *testing.T) {
+	large := strings.Repeat("hello ", 100)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.String(http.StatusOK, large) }),
+		WithETag(),
+		WithSpillToDisk(64, t.TempDir()),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, bodyETag([]byte(large)), w.Header().Get("ETag"))
+	assert.Equal(t, large, w.Body.String())
+}
+
+func TestWithNotModified(t *testing.T) {
+	var status int
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		status = c.Writer.Status()
+	})
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithNotModified(),
+	))
+	r.GET("/", func(c *gin.Context) {
+		c.Header("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, "hello")
+	})
+	etag := bodyETag([]byte("hello"))
+
+	for ifNoneMatch, code := range map[string]int{
+		"":                   http.StatusOK,
+		`"other"`:            http.StatusOK,
+		etag:                 http.StatusNotModified,
+		`"other", W/` + etag: http.StatusNotModified,
+		"*":                  http.StatusNotModified,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, ifNoneMatch)
+		assert.Equal(t, code, status, ifNoneMatch)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, "max-age=60", w.Header().Get("Cache-Control"))
+		if code == http.StatusNotModified {
+			assert.Empty(t, w.Body.String())
+			assert.Empty(t, w.Header().Get("Content-Type"))
+		} else {
+			assert.Equal(t, "hello", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package timeout
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flight is a handler execution shared by identical concurrent requests
+type flight struct {
+	done chan struct{}
+	// resp is the response of the leader, set by the leader before it lands
+	// and valid for the other requests once done is closed if ok
+	resp Partial
+	ok   bool
+}
+
+// flightGroup tracks the executions in flight by request key
+type flightGroup struct {
+	mu      sync.Mutex
+	vary    []string
+	flights map[string]*flight
+}
+
+// key identifies the requests that can share a response
+func (g *flightGroup) key(c *gin.Context) string {
+	return requestKey(c, g.vary)
+}
+
+// join returns the flight of a key and whether the caller leads it
+func (g *flightGroup) join(key string) (*flight, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	return f, true
+}
+
+// land publishes the leader's response to the waiting requests
+func (g *flightGroup) land(key string, f *flight) {
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+
+	close(f.done)
+}
+
+// awaitFlight waits for the leader of a flight, bounded by the request's own
+// timeout. It reports whether the request was answered and otherwise the
+// budget left to run the handler itself.
+func (t *Timeout) awaitFlight(c *gin.Context, f *flight, timeout time.Duration, arrival time.Time) (bool, time.Duration) {
+	start := t.clock.Now()
+	select {
+	case <-f.done:
+		if !f.ok {
+			return false, max(timeout-t.clock.Now().Sub(start), time.Nanosecond)
+		}
+		c.Abort()
+		t.setDurations(c, arrival, start, false)
+		writePartial(c, f.resp)
+		return true, 0
+
+	case <-t.clock.After(timeout):
+		c.Abort()
+		c.Set(timedOutKey, true)
+		t.setDurations(c, arrival, start, true)
+		t.countTimeout(c)
+		c.Set(infoKey, t.timeoutInfo(c, PhaseProcess, timeout))
+		_ = c.Error(ErrTimeout)
+		t.Response()(c)
+		t.hooks.timeout(c)
+		return true, 0
+	}
+}
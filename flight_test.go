@@ -0,0 +1,47 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	var calls atomic.Int32
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			c.String(http.StatusOK, "shared")
+		}),
+		WithCoalesce(),
+	))
+
+	var wg sync.WaitGroup
+	recorders := make([]*httptest.ResponseRecorder, 5)
+	for i := range recorders {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.ServeHTTP(w, req)
+		}(recorders[i])
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, w := range recorders {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "shared", w.Body.String())
+	}
+}
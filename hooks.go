@@ -0,0 +1,43 @@
+package timeout
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Hooks are called at each stage of a request handled by the middleware,
+// nil fields are skipped. They run on the request goroutine and must not block.
+type Hooks struct {
+	// OnStart is called right before the handler starts
+	OnStart func(c *gin.Context)
+	// OnFinish is called after a handler that finished in time was flushed
+	OnFinish func(c *gin.Context)
+	// OnTimeout is called after the timeout response was sent
+	OnTimeout func(c *gin.Context)
+	// OnPanic is called with the recovered value and its stack, before the
+	// panic is turned into a response or re-raised
+	OnPanic func(c *gin.Context, p any, stack []byte)
+}
+
+func (h *Hooks) start(c *gin.Context) {
+	if h.OnStart != nil {
+		h.OnStart(c)
+	}
+}
+
+func (h *Hooks) finish(c *gin.Context) {
+	if h.OnFinish != nil {
+		h.OnFinish(c)
+	}
+}
+
+func (h *Hooks) timeout(c *gin.Context) {
+	if h.OnTimeout != nil {
+		h.OnTimeout(c)
+	}
+}
+
+func (h *Hooks) panic(c *gin.Context, p any, stack []byte) {
+	if h.OnPanic != nil {
+		h.OnPanic(c, p, stack)
+	}
+}
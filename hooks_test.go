@@ -0,0 +1,47 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	var events []string
+	r := gin.New()
+	r.GET("/:kind", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			switch c.Param("kind") {
+			case "slow":
+				time.Sleep(100 * time.Millisecond)
+			case "panic":
+				panic("boom")
+			}
+			c.Status(http.StatusOK)
+		}),
+		WithPanicHandler(func(c *gin.Context, p any, stack []byte) {
+			c.Status(http.StatusInternalServerError)
+		}),
+		WithHooks(Hooks{
+			OnStart:   func(c *gin.Context) { events = append(events, "start") },
+			OnFinish:  func(c *gin.Context) { events = append(events, "finish") },
+			OnTimeout: func(c *gin.Context) { events = append(events, "timeout") },
+			OnPanic: func(c *gin.Context, p any, stack []byte) {
+				events = append(events, "panic:"+p.(string))
+			},
+		}),
+	))
+
+	for _, path := range []string{"/ok", "/slow", "/panic"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	assert.Equal(t, []string{"start", "finish", "start", "timeout", "start", "panic:boom"}, events)
+}
@@ -0,0 +1,40 @@
+package timeout
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders only apply to a single connection and must not be passed
+// on from a buffered header map
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"TE",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// stripHeaders removes the hop-by-hop headers, those the Connection header
+// names and the configured extra headers from h
+func (t *Timeout) stripHeaders(h http.Header) {
+	if !t.stripHopByHop {
+		return
+	}
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+	for _, name := range t.strippedHeaders {
+		h.Del(name)
+	}
+}
@@ -0,0 +1,23 @@
+package timeout
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPHandler wraps a plain net/http handler with the same buffered timeout
+// machinery as New, so mixed gin and net/http services share one implementation.
+// Any WithHandler option is replaced by next. Route based options do not apply
+// since requests are not routed by gin.
+func HTTPHandler(next http.Handler, opts ...Option) http.Handler {
+	opts = append(opts[:len(opts):len(opts)], WithHandler(gin.WrapH(next)))
+
+	engine := gin.New()
+	engine.NoRoute(
+		// requests never match a route, undo the 404 status gin presets for them
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+		New(opts...),
+	)
+	return engine
+}
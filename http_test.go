@@ -0,0 +1,38 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	h := HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Microsecond)
+		}
+		w.Header().Set("X-Test", "1")
+		_, _ = w.Write([]byte("hello"))
+	}), WithTimeout(50*time.Microsecond))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/slow", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+
+	h = HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+		_, _ = w.Write([]byte("hello"))
+	}), WithTimeout(1*time.Second))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "PROPFIND", "/any/path", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Test"))
+	assert.Equal(t, "hello", w.Body.String())
+}
@@ -0,0 +1,226 @@
+package timeout
+
+import (
+	"expvar"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recorder receives metrics from the middleware, implement it to plug in any
+// telemetry system. Methods are called concurrently and must not block.
+type Recorder interface {
+	// ObserveDuration records how long the handler ran and the end-to-end
+	// latency from request arrival, which includes queueing
+	ObserveDuration(route string, elapsed, latency time.Duration)
+	// IncTimeout counts a request that timed out
+	IncTimeout(route string)
+	// IncPanic counts a handler that panicked
+	IncPanic(route string)
+	// IncRejected counts a request rejected before its handler ran
+	IncRejected(route string)
+}
+
+// ClientGoneRecorder is optionally implemented by a Recorder to count
+// requests abandoned because the client disconnected, see WithClientGone
+type ClientGoneRecorder interface {
+	IncClientGone(route string)
+}
+
+// StageRecorder is optionally implemented by a Recorder to receive the time
+// a timed out request spent in each stage marked with Annotate
+type StageRecorder interface {
+	ObserveStage(route, stage string, d time.Duration)
+}
+
+// ExemplarRecorder is optionally implemented by a Recorder to attach the
+// trace ID of a request, found by WithTraceID, as an exemplar to its duration
+// and timeout metrics. The plain methods are used for untraced requests.
+type ExemplarRecorder interface {
+	ObserveDurationWithExemplar(route string, elapsed, latency time.Duration, traceID string)
+	IncTimeoutWithExemplar(route, traceID string)
+}
+
+// exemplar returns the recorder and trace ID to report an exemplar with, ok
+// is false when the request has no trace or the recorder takes no exemplars
+func (t *Timeout) exemplar(c *gin.Context) (r ExemplarRecorder, traceID string, ok bool) {
+	if t.traceID == nil {
+		return nil, "", false
+	}
+	if r, ok = t.recorder.(ExemplarRecorder); !ok {
+		return nil, "", false
+	}
+	traceID = t.traceID(c)
+	return r, traceID, traceID != ""
+}
+
+// reject aborts the request with the reject response
+func (t *Timeout) reject(c *gin.Context) {
+	c.Abort()
+	t.countRejected(c)
+	t.rejectResponse(c)
+}
+
+// countRequest counts a request entering the middleware
+func (t *Timeout) countRequest() {
+	if t.expvars != nil {
+		t.expvars.Add("requests", 1)
+	}
+}
+
+// observeDuration records the durations of a request whose handler ran,
+// timedOut includes the timeouts only recorded in shadow mode
+func (t *Timeout) observeDuration(c *gin.Context, elapsed, latency time.Duration, timedOut bool) {
+	if r, traceID, ok := t.exemplar(c); ok {
+		r.ObserveDurationWithExemplar(c.FullPath(), elapsed, latency, traceID)
+	} else if t.recorder != nil {
+		t.recorder.ObserveDuration(c.FullPath(), elapsed, latency)
+	}
+	if t.stats != nil {
+		t.stats.observe(c.FullPath(), elapsed)
+	}
+	if t.alert != nil {
+		t.alert.observe(c.FullPath(), timedOut, t.clock.Now())
+	}
+	if t.spikes != nil {
+		t.spikes.observe(c.FullPath(), timedOut, t.clock.Now())
+	}
+	if t.readiness != nil {
+		t.readiness.observe(timedOut, t.clock.Now())
+	}
+}
+
+// countTimeout counts a request that timed out
+func (t *Timeout) countTimeout(c *gin.Context) {
+	if t.stats != nil {
+		t.stats.incTimeout(c.FullPath())
+	}
+	if r, traceID, ok := t.exemplar(c); ok {
+		r.IncTimeoutWithExemplar(c.FullPath(), traceID)
+	} else if t.recorder != nil {
+		t.recorder.IncTimeout(c.FullPath())
+	}
+	if t.expvars != nil {
+		t.expvars.Add("timeouts", 1)
+	}
+}
+
+// countClientGone counts a request abandoned because the client disconnected
+func (t *Timeout) countClientGone(c *gin.Context) {
+	if t.stats != nil {
+		t.stats.incClientGone(c.FullPath())
+	}
+	if r, ok := t.recorder.(ClientGoneRecorder); ok {
+		r.IncClientGone(c.FullPath())
+	}
+	if t.expvars != nil {
+		t.expvars.Add("client_gone", 1)
+	}
+}
+
+// observeStages reports the stage breakdown of a timed out request
+func (t *Timeout) observeStages(c *gin.Context, stages []StageTiming) {
+	r, ok := t.recorder.(StageRecorder)
+	if !ok {
+		return
+	}
+	for _, s := range stages {
+		r.ObserveStage(c.FullPath(), s.Stage, s.Duration)
+	}
+}
+
+// countPanic counts a handler that panicked
+func (t *Timeout) countPanic(c *gin.Context) {
+	if t.stats != nil {
+		t.stats.incPanic(c.FullPath())
+	}
+	if t.recorder != nil {
+		t.recorder.IncPanic(c.FullPath())
+	}
+	if t.expvars != nil {
+		t.expvars.Add("panics", 1)
+	}
+}
+
+// countRejected counts a request rejected before its handler ran
+func (t *Timeout) countRejected(c *gin.Context) {
+	if t.recorder != nil {
+		t.recorder.IncRejected(c.FullPath())
+	}
+	if t.expvars != nil {
+		t.expvars.Add("rejected", 1)
+	}
+}
+
+// expvarGroup is an expvar map published by WithExpvar, shared by all the
+// middlewares using its name. Counters are added to by each of them, the
+// gauges sum them up.
+type expvarGroup struct {
+	vars    *expvar.Map
+	mu      sync.Mutex
+	members []*Timeout
+}
+
+// expvarGroups are the groups published so far by name
+var expvarGroups = struct {
+	sync.Mutex
+	m map[string]*expvarGroup
+}{m: make(map[string]*expvarGroup)}
+
+// expvarConflict reports an error if name is published by something other
+// than WithExpvar, expvar panics when a name is published twice
+func expvarConflict(name string) error {
+	expvarGroups.Lock()
+	defer expvarGroups.Unlock()
+	if _, ok := expvarGroups.m[name]; !ok && expvar.Get(name) != nil {
+		return fmt.Errorf("timeout: expvar %q is already published", name)
+	}
+	return nil
+}
+
+// publishExpvar publishes the counters under prefix, or joins the group
+// already published there by another middleware
+func (t *Timeout) publishExpvar(prefix string) {
+	expvarGroups.Lock()
+	defer expvarGroups.Unlock()
+
+	g, ok := expvarGroups.m[prefix]
+	if !ok {
+		if expvar.Get(prefix) != nil {
+			// published by someone else since the option was checked
+			return
+		}
+		g = &expvarGroup{vars: expvar.NewMap(prefix)}
+		for _, k := range []string{"requests", "timeouts", "panics", "rejected", "client_gone"} {
+			g.vars.Add(k, 0)
+		}
+		g.vars.Set("in_flight", expvar.Func(func() any { return g.sum(func(t *Timeout) int64 { return t.inFlight.Load() }) }))
+		g.vars.Set("abandoned", expvar.Func(func() any { return g.sum(func(t *Timeout) int64 { return t.abandoned.Load() }) }))
+		expvarGroups.m[prefix] = g
+	}
+	g.join(t)
+	t.expvars = g.vars
+}
+
+// join adds t to the group once
+func (g *expvarGroup) join(t *Timeout) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !slices.Contains(g.members, t) {
+		g.members = append(g.members, t)
+	}
+}
+
+// sum adds up a gauge over the members of the group
+func (g *expvarGroup) sum(gauge func(t *Timeout) int64) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var total int64
+	for _, t := range g.members {
+		total += gauge(t)
+	}
+	return total
+}
@@ -0,0 +1,170 @@
+package timeout
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingRecorder struct {
+	mu        sync.Mutex
+	durations int
+	timeouts  map[string]int
+	panics    int
+	rejected  int
+}
+
+func (r *countingRecorder) ObserveDuration(route string, elapsed, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.durations++
+}
+
+func (r *countingRecorder) IncTimeout(route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeouts[route]++
+}
+
+func (r *countingRecorder) IncPanic(route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panics++
+}
+
+func (r *countingRecorder) IncRejected(route string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected++
+}
+
+func TestRecorder(t *testing.T) {
+	rec := &countingRecorder{timeouts: map[string]int{}}
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(New(
+		WithTimeout(50*time.Millisecond),
+		WithRecorder(rec),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithAdmission(func(c *gin.Context, l Load) bool {
+			return c.Request.URL.Path != "/rejected"
+		}),
+	))
+	r.GET("/ok", func(c *gin.Context) {})
+	r.GET("/slow", func(c *gin.Context) { time.Sleep(100 * time.Millisecond) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/rejected", func(c *gin.Context) {})
+
+	for _, path := range []string{"/ok", "/slow", "/panic", "/rejected"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	assert.Equal(t, 3, rec.durations)
+	assert.Equal(t, map[string]int{"/slow": 1}, rec.timeouts)
+	assert.Equal(t, 1, rec.panics)
+	assert.Equal(t, 1, rec.rejected)
+}
+
+func TestExpvar(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(emptySuccessResponse),
+		WithExpvar("timeout_test"),
+	))
+	// a second middleware sharing the prefix must not panic
+	New(WithHandler(emptySuccessResponse), WithExpvar("timeout_test"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	m := expvar.Get("timeout_test").(*expvar.Map)
+	assert.Equal(t, "1", m.Get("requests").String())
+	assert.Equal(t, "1", m.Get("timeouts").String())
+	assert.Equal(t, "0", m.Get("panics").String())
+	assert.NotNil(t, m.Get("in_flight"))
+	assert.NotNil(t, m.Get("abandoned"))
+}
+
+func TestExpvarShared(t *testing.T) {
+	// expvar names stay published, use a fresh one on every run
+	name := fmt.Sprintf("timeout_test_shared_%d", time.Now().UnixNano())
+	a := NewTimeout(WithHandler(emptySuccessResponse), WithExpvar(name))
+	b := NewTimeout(WithHandler(emptySuccessResponse), WithExpvar(name))
+	a.Middleware()
+	b.Middleware()
+	a.Middleware()
+	a.inFlight.Store(2)
+	b.inFlight.Store(3)
+	b.abandoned.Store(1)
+
+	m := expvar.Get(name).(*expvar.Map)
+	assert.Equal(t, "5", m.Get("in_flight").String())
+	assert.Equal(t, "1", m.Get("abandoned").String())
+}
+
+func TestExpvarConflict(t *testing.T) {
+	if expvar.Get("timeout_test_taken") == nil {
+		expvar.NewString("timeout_test_taken")
+		expvar.NewMap("timeout_test_foreign_map")
+	}
+	for _, name := range []string{"timeout_test_taken", "timeout_test_foreign_map"} {
+		_, err := NewE(WithHandler(emptySuccessResponse), WithExpvar(name))
+		assert.Error(t, err, name)
+		assert.Panics(t, func() { New(WithHandler(emptySuccessResponse), WithExpvar(name)) }, name)
+	}
+}
+
+type exemplarRecorder struct {
+	countingRecorder
+	exemplars []string
+}
+
+func (r *exemplarRecorder) ObserveDurationWithExemplar(route string, elapsed, latency time.Duration, traceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exemplars = append(r.exemplars, "duration:"+traceID)
+}
+
+func (r *exemplarRecorder) IncTimeoutWithExemplar(route, traceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exemplars = append(r.exemplars, "timeout:"+traceID)
+}
+
+func TestExemplars(t *testing.T) {
+	rec := &exemplarRecorder{countingRecorder: countingRecorder{timeouts: map[string]int{}}}
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(10*time.Millisecond),
+		WithRecorder(rec),
+		WithTraceID(TraceParentID),
+		WithHandler(func(c *gin.Context) { time.Sleep(30 * time.Millisecond) }),
+	))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	// untraced requests use the plain methods
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	assert.ElementsMatch(t, []string{"timeout:" + traceID, "duration:" + traceID}, rec.exemplars)
+	assert.Equal(t, map[string]int{"/": 1}, rec.timeouts)
+	assert.Equal(t, 1, rec.durations)
+}
@@ -1,7 +1,15 @@
 package timeout
 
 import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,7 +21,74 @@ type Option func(*Timeout)
 // WithTimeout set timeout
 func WithTimeout(timeout time.Duration) Option {
 	return func(t *Timeout) {
-		t.timeout = timeout
+		t.SetTimeout(timeout)
+	}
+}
+
+// WithMinTimeout set the floor applied to every resolved timeout, including
+// shorter budgets sent by the caller in the timeout header
+func WithMinTimeout(d time.Duration) Option {
+	return func(t *Timeout) {
+		t.minTimeout = d
+	}
+}
+
+// WithMaxTimeout set the ceiling applied to every resolved timeout,
+// it also replaces budgets that would otherwise disable the timeout
+func WithMaxTimeout(d time.Duration) Option {
+	return func(t *Timeout) {
+		t.maxTimeout = d
+	}
+}
+
+// WithRouteTimeouts set timeouts per route full path, overriding the default one
+func WithRouteTimeouts(timeouts map[string]time.Duration) Option {
+	return func(t *Timeout) {
+		t.routeTimeouts = timeouts
+	}
+}
+
+// WithPolicySource set a live source of route policies, it takes precedence
+// over WithRouteTimeouts. The policy of a route is fetched once and kept
+// until the source reports a change through Watch.
+func WithPolicySource(src PolicySource) Option {
+	return func(t *Timeout) {
+		t.policySource = src
+	}
+}
+
+// WithRoutePatterns set timeouts per route pattern. A pattern ending in "*"
+// matches every full path with that prefix, the longest match wins and exact
+// patterns or WithRouteTimeouts entries take precedence.
+func WithRoutePatterns(patterns map[string]time.Duration) Option {
+	return func(t *Timeout) {
+		t.routePatterns = compilePatterns(patterns)
+	}
+}
+
+// WithStaticFastPath serves the requests match reports true for straight
+// through the original writer, so files can go out with sendfile instead of
+// being buffered. A nil match falls back to StaticRoute, which relies on gin
+// internals. Like skipped paths these requests are not bounded by the timeout.
+func WithStaticFastPath(match func(c *gin.Context) bool) Option {
+	return func(t *Timeout) {
+		t.staticFast = true
+		t.unbuffered = match
+	}
+}
+
+// WithSkipPathRegexp runs the handler without timeout for request paths
+// matching any of the patterns, for example "^/static/" or "^/ws/"
+func WithSkipPathRegexp(patterns ...string) Option {
+	return func(t *Timeout) {
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				t.optionErr = fmt.Errorf("timeout: invalid skip path pattern %q: %w", p, err)
+				return
+			}
+			t.skipPaths = append(t.skipPaths, re)
+		}
 	}
 }
 
@@ -27,17 +102,803 @@ func WithHandler(h gin.HandlerFunc) Option {
 // WithResponse add gin handler
 func WithResponse(h gin.HandlerFunc) Option {
 	return func(t *Timeout) {
-		t.response = h
+		t.SetResponse(h)
+	}
+}
+
+// WithRequestID reads a correlation ID from the context key key, falling back
+// to the request then the response header of that name (X-Request-ID for
+// gin-contrib/requestid). The ID is included in the built-in responses and
+// LogPanic output and is available through RequestID.
+func WithRequestID(key string) Option {
+	return func(t *Timeout) {
+		t.requestIDKey = key
+	}
+}
+
+// WithErrorReporter sends recovered panics, with their stack, and timeouts,
+// with ErrTimeout and a nil stack, to f, e.g. an error tracking client.
+// Panics are reported even when they are re-raised.
+func WithErrorReporter(f func(c *gin.Context, err error, stack []byte)) Option {
+	return func(t *Timeout) {
+		t.errorReporter = f
+	}
+}
+
+// WithHooks attaches h to the stages of every request, see Hooks
+func WithHooks(h Hooks) Option {
+	return func(t *Timeout) {
+		t.hooks = h
+	}
+}
+
+// WithClientGone abandons the handler as soon as the client disconnects and
+// runs h instead of the timeout response, the request is counted as client
+// gone rather than as a timeout. Use ClientGoneResponse to only record 499.
+func WithClientGone(h gin.HandlerFunc) Option {
+	return func(t *Timeout) {
+		t.clientGone = h
+	}
+}
+
+// WithRetry runs the handler again, with a fresh budget, up to n times when
+// it times out before writing anything to the client. shouldRetry decides
+// per request, nil only retries GET, HEAD and OPTIONS. Retries run on a copy
+// of the context, the Keys and Errors of the one that answers are merged back.
+func WithRetry(n int, shouldRetry func(c *gin.Context) bool) Option {
+	return func(t *Timeout) {
+		t.retries = n
+		t.retryIf = shouldRetry
+	}
+}
+
+// WithSharedKeys sets up a SharedKeys store before the handler runs, see
+// Shared. Unlike c.Set, values stored there by any execution of the handler
+// are visible to the others and to later middlewares right away.
+func WithSharedKeys() Option {
+	return func(t *Timeout) {
+		t.sharedKeys = true
+	}
+}
+
+// WithHedge starts a second execution of the handler of GET, HEAD and
+// OPTIONS requests once the fraction after of the budget has elapsed, the
+// first one to return is sent and the other is discarded. Hedged executions
+// run on a copy of the context like retries. Since two executions must never
+// write to the client at once, hedging cannot be combined with
+// WithWriteThrough, WithStreamAfter or WithReaderStreaming, and passthrough
+// content types such as event streams are buffered like any other response.
+func WithHedge(after float64) Option {
+	return func(t *Timeout) {
+		t.hedgeAfter = after
+	}
+}
+
+// WithTierFunc sets how a request is mapped to a tier, e.g. from an API key
+// header or auth state set by an earlier middleware, see WithTiers
+func WithTierFunc(f func(c *gin.Context) string) Option {
+	return func(t *Timeout) {
+		t.tierFunc = f
+	}
+}
+
+// WithTiers sets the timeout of each tier returned by the WithTierFunc
+// function, requests in an unknown tier use the route timeout
+func WithTiers(tiers map[string]time.Duration) Option {
+	return func(t *Timeout) {
+		t.tiers = tiers
+	}
+}
+
+// WithFallback replaces the timeout response with f, which receives what the
+// handler had buffered so far and can serve a degraded result from it.
+// Headers are taken as the handler left them the last time it called the writer.
+func WithFallback(f func(c *gin.Context, partial Partial)) Option {
+	return func(t *Timeout) {
+		t.fallback = f
+	}
+}
+
+// WithPartialFlush sends what the handler had buffered when it timed out,
+// with its headers and status, followed by marker, instead of the timeout response
+func WithPartialFlush(marker string) Option {
+	return WithFallback(partialResponse(marker))
+}
+
+// WithStaleCache stores successful buffered responses in cache and serves the
+// stored one when a later request with the same key times out, with header
+// set to its age in seconds. Only GET and HEAD responses without Set-Cookie
+// or a private or no-store Cache-Control directive are stored, since they
+// may be served to other callers.
+func WithStaleCache(cache *StaleCache, header string) Option {
+	return func(t *Timeout) {
+		if cache == nil || cache.maxEntries <= 0 {
+			t.optionErr = errors.New("timeout: stale cache must hold at least one entry")
+			return
+		}
+		t.cache = cache
+		t.staleHeader = header
+	}
+}
+
+// WithCoalesce runs the handler once for identical concurrent GET, HEAD and
+// OPTIONS requests, keyed by request URI and the vary headers, and sends its
+// successful response to all of them. Each request still times out on its
+// own deadline, and runs the handler itself if the shared execution failed.
+func WithCoalesce(vary ...string) Option {
+	return func(t *Timeout) {
+		t.flights = &flightGroup{vary: vary, flights: make(map[string]*flight)}
+	}
+}
+
+// WithAsync answers timed out requests with 202 Accepted and a job ID
+// instead of the timeout response, the handler keeps running in the
+// background and f receives its response once it returns
+func WithAsync(f JobFunc) Option {
+	return func(t *Timeout) {
+		t.async = f
+	}
+}
+
+// WithETag sets a strong ETag, a hash of the buffered body, on successful
+// GET and HEAD responses the handler did not give one
+func WithETag() Option {
+	return func(t *Timeout) {
+		t.etag = true
+	}
+}
+
+// WithNotModified answers a 304 Not Modified without body, instead of the
+// buffered response, when the If-None-Match header of a GET or HEAD request
+// matches its ETag. It implies WithETag.
+func WithNotModified() Option {
+	return func(t *Timeout) {
+		t.etag = true
+		t.conditional = true
+	}
+}
+
+// WithFlushInterceptor calls f with the buffered response of every request
+// that finished in time, before it is sent, e.g. to sign, minify or redact
+// it. f may change header and body in place; the ETag and compression apply
+// to the result. If f returns an error, a 500 is sent in place of the response.
+// A body spilled to disk is not passed to f, see WithSpillToDisk.
+func WithFlushInterceptor(f func(c *gin.Context, status int, header http.Header, body *bytes.Buffer) error) Option {
+	return func(t *Timeout) {
+		t.interceptor = f
+	}
+}
+
+// WithDigest sets an integrity header computed over the buffered body as it
+// is sent, after compression, on responses that finished in time
+func WithDigest(alg DigestAlgorithm) Option {
+	return func(t *Timeout) {
+		t.digest = alg
+	}
+}
+
+// WithStripHeaders removes hop-by-hop headers such as Connection and
+// Transfer-Encoding, and the extra headers listed, from the buffered header
+// map before it is flushed
+func WithStripHeaders(extra ...string) Option {
+	return func(t *Timeout) {
+		t.stripHopByHop = true
+		t.strippedHeaders = extra
+	}
+}
+
+// WithCompression gzips buffered bodies of at least minSize bytes at flush
+// time when the Accept-Encoding of the client allows it and the handler did
+// not encode the response itself. Use it instead of a compressing middleware
+// wrapping the writer, which conflicts with the buffering. A body spilled to
+// disk is sent uncompressed, see WithSpillToDisk.
+func WithCompression(minSize int) Option {
+	return func(t *Timeout) {
+		t.compression = true
+		t.compressMin = minSize
+	}
+}
+
+// WithServerTiming adds a Server-Timing header with the handler duration and
+// the budget, e.g. "handler;dur=123.4, budget;dur=500.0", to successful and
+// timeout responses
+func WithServerTiming() Option {
+	return func(t *Timeout) {
+		t.serverTiming = true
+	}
+}
+
+// WithDurationHeader sets the header name, e.g. X-Response-Time, to the
+// measured handler duration on the responses sent by the middleware
+func WithDurationHeader(name string) Option {
+	return func(t *Timeout) {
+		t.durationHeader = name
+	}
+}
+
+// WithDryRun only measures: requests exceeding their timeout are counted and
+// the OnTimeout hook fires, but the handler still answers them. Check
+// WouldHaveTimedOut in later middlewares.
+func WithDryRun(enabled bool) Option {
+	return func(t *Timeout) {
+		t.dryRun = enabled
+	}
+}
+
+// WithEnforcementRatio only enforces the timeout on the fraction p of the
+// requests, the others run in shadow mode as with WithDryRun, so enforcement
+// can be ramped up gradually
+func WithEnforcementRatio(p float64) Option {
+	return func(t *Timeout) {
+		t.enforceRatio = p
+	}
+}
+
+// WithStartAfterBody starts the timeout once the handler read the request
+// body to the end or closed it, so slow uploads do not eat the processing
+// budget. Requests without a body start right away. A handler that never
+// reads the body is not bounded, use it on routes that always read it.
+func WithStartAfterBody() Option {
+	return func(t *Timeout) {
+		t.startAfterBody = true
+	}
+}
+
+// WithStatus makes the built-in timeout responses send code instead of 408,
+// typically http.StatusGatewayTimeout or http.StatusServiceUnavailable. It
+// applies to the plain text response as well as JSONResponse, XMLResponse,
+// NegotiatedResponse and WithProblemDetails.
+func WithStatus(code int) Option {
+	return func(t *Timeout) {
+		t.status = code
+	}
+}
+
+// WithWriterFactory replaces the buffered writer implementation
+func WithWriterFactory(f WriterFactory) Option {
+	return func(t *Timeout) {
+		t.writerFactory = f
+	}
+}
+
+// WithMaxConcurrent limits the number of handler goroutines running at once
+func WithMaxConcurrent(n int) Option {
+	return func(t *Timeout) {
+		t.maxConcurrent = n
+	}
+}
+
+// WithMaxConcurrentWait set how long a request waits for a free slot
+// before it is rejected, zero rejects immediately
+func WithMaxConcurrentWait(d time.Duration) Option {
+	return func(t *Timeout) {
+		t.maxConcurrentWait = d
+	}
+}
+
+// WithAdmission add a hook deciding whether a request is admitted,
+// rejected requests get the reject response without running the handler
+func WithAdmission(f AdmissionFunc) Option {
+	return func(t *Timeout) {
+		t.admission = f
+	}
+}
+
+// WithClock set the clock used to measure deadlines
+func WithClock(clock Clock) Option {
+	return func(t *Timeout) {
+		t.clock = clock
+	}
+}
+
+// WithInjectedLatency delays every handler by a delay sampled from dist,
+// meant for staging load tests of timeout budgets
+func WithInjectedLatency(dist LatencyDistribution) Option {
+	return func(t *Timeout) {
+		t.latency = dist
+	}
+}
+
+// WithTimeoutStack captures the stack of the handler goroutine when the
+// timeout fires, available as Info.Stack, e.g. from the OnTimeout hook
+// through TimeoutInfo. Handler goroutines are given a pprof label for it.
+func WithTimeoutStack() Option {
+	return func(t *Timeout) {
+		t.timeoutStack = true
+	}
+}
+
+// WithTrace records every request as a runtime/trace task with regions for
+// setting up the buffer, running the handler and flushing the response.
+// Handlers can add their own regions through c.Request.Context().
+func WithTrace() Option {
+	return func(t *Timeout) {
+		t.trace = true
+	}
+}
+
+// WithGoroutineDump captures the stacks of all goroutines on a sample of
+// timeouts, rate ranges from 0 (never) to 1 (every timeout)
+func WithGoroutineDump(rate float64, f DumpFunc) Option {
+	return func(t *Timeout) {
+		t.dumpRate = rate
+		t.dump = f
+	}
+}
+
+// WithStartTime set how the arrival time of a request is determined, for
+// example from a header stamped by a load balancer, defaults to the time
+// the middleware is entered
+func WithStartTime(f func(c *gin.Context) time.Time) Option {
+	return func(t *Timeout) {
+		t.startTime = f
+	}
+}
+
+// WithPanicResponse responds with h instead of re-panicking when a panic
+// recovered from the handler matches, the first matching mapping wins
+func WithPanicResponse(match PanicMatcher, h gin.HandlerFunc) Option {
+	return func(t *Timeout) {
+		t.panicResponses = append(t.panicResponses, panicMapping{match: match, response: h})
+	}
+}
+
+// WithPanicHandler handles panics recovered from the handler instead of
+// re-panicking, mappings added with WithPanicResponse take precedence
+func WithPanicHandler(h PanicHandler) Option {
+	return func(t *Timeout) {
+		t.panicHandler = h
+	}
+}
+
+// WithStackLimit limits the stack captured for panicking handlers to
+// maxBytes bytes and maxFrames frames, zero means no limit
+func WithStackLimit(maxBytes, maxFrames int) Option {
+	return func(t *Timeout) {
+		t.stackMaxBytes = maxBytes
+		t.stackMaxFrames = maxFrames
+	}
+}
+
+// WithoutStackCapture disables stack capture for panicking handlers,
+// panic handlers then receive a nil stack
+func WithoutStackCapture() Option {
+	return func(t *Timeout) {
+		t.stackDisabled = true
+	}
+}
+
+// WithCompletionReport keeps track of handlers abandoned by a timeout and
+// calls f once they eventually finish, to log or alert on what the slow work did
+func WithCompletionReport(f CompletionFunc) Option {
+	return func(t *Timeout) {
+		t.completion = f
+	}
+}
+
+// WithCompletionNotifier calls f once a handler abandoned by a timeout, or
+// moved to the background by WithAsync, eventually finishes, for example to
+// notify an external system or update a job record
+func WithCompletionNotifier(f func(info CompletionInfo)) Option {
+	return func(t *Timeout) {
+		t.notifier = f
+	}
+}
+
+// WithAbandonedThreshold calls f each time the number of handler goroutines
+// running past their deadline reaches n, a sign of handlers ignoring cancellation
+func WithAbandonedThreshold(n int64, f func(count int64)) Option {
+	return func(t *Timeout) {
+		t.abandonedThreshold = n
+		t.onAbandoned = f
+	}
+}
+
+// WithTimeoutRateAlert calls f when the fraction of requests timing out over
+// the sliding window reaches threshold, overall and per route. It fires once
+// per crossing and again only after the rate fell back below threshold.
+func WithTimeoutRateAlert(window time.Duration, threshold float64, f RateAlertFunc) Option {
+	return func(t *Timeout) {
+		t.alert = newRateAlert(window, threshold, f)
+	}
+}
+
+// WithReadiness makes Timeout.Ready fail while the overall fraction of
+// requests timing out over the sliding window reaches maxRate, or while at
+// least maxAbandoned handlers run past their deadline if maxAbandoned is
+// positive, so a struggling instance is taken out of rotation
+func WithReadiness(window time.Duration, maxRate float64, maxAbandoned int64) Option {
+	return func(t *Timeout) {
+		t.readiness = &readiness{
+			width:        max(window/alertBuckets, time.Nanosecond),
+			maxRate:      maxRate,
+			maxAbandoned: maxAbandoned,
+		}
+	}
+}
+
+// WithProfileOnSpike captures a goroutine profile, and a CPU profile lasting
+// cpu if positive, when the overall timeout rate over window reaches
+// threshold, at most once per cooldown, and passes them to sink
+func WithProfileOnSpike(window time.Duration, threshold float64, cooldown, cpu time.Duration, sink ProfileSink) Option {
+	return func(t *Timeout) {
+		p := &spikeProfiler{cooldown: cooldown, cpu: cpu, sink: sink}
+		t.profiler = p
+		t.spikes = newRateAlert(window, threshold, p.trigger)
+	}
+}
+
+// WithPreservedHeaders carries the listed headers the handler had already set
+// onto the timeout response, for example CORS or request id headers. Headers
+// are taken as the handler left them the last time it called the writer.
+func WithPreservedHeaders(keys ...string) Option {
+	return func(t *Timeout) {
+		t.preservedHeaders = make([]string, 0, len(keys))
+		for _, k := range keys {
+			t.preservedHeaders = append(t.preservedHeaders, http.CanonicalHeaderKey(k))
+		}
+	}
+}
+
+// WithRecorder set the Recorder receiving the middleware metrics
+func WithRecorder(r Recorder) Option {
+	return func(t *Timeout) {
+		t.recorder = r
+	}
+}
+
+// WithTraceID sets how the trace ID of a request is found, e.g. from the
+// span set by a tracing middleware or with TraceParentID. A Recorder that
+// implements ExemplarRecorder then gets it as an exemplar.
+func WithTraceID(f func(c *gin.Context) string) Option {
+	return func(t *Timeout) {
+		t.traceID = f
+	}
+}
+
+// WithExpvar publishes request, timeout, panic, rejection, in-flight and
+// abandoned counters as an expvar map named prefix, served on /debug/vars.
+// Middlewares sharing a prefix report their totals together. The prefix must
+// not be published by anything else.
+func WithExpvar(prefix string) Option {
+	return func(t *Timeout) {
+		if err := expvarConflict(prefix); err != nil {
+			t.optionErr = err
+			return
+		}
+		t.expvarPrefix = prefix
+	}
+}
+
+// WithStats collects per route counters and latency percentiles, read them with Timeout.Stats
+func WithStats() Option {
+	return func(t *Timeout) {
+		t.stats = newStatsCollector()
+	}
+}
+
+// WithRecentTimeouts keeps the last n timed out requests in memory, with
+// sensitive headers redacted, read them with Timeout.RecentTimeouts or serve
+// them with Timeout.RecentTimeoutsHandler
+func WithRecentTimeouts(n int) Option {
+	return func(t *Timeout) {
+		if n <= 0 {
+			t.recent = nil
+			return
+		}
+		t.recent = newRecentTimeouts(n)
+	}
+}
+
+// WithOnLateWrite calls f each time a handler keeps writing after its timeout,
+// with the number of discarded bytes or 0 for a WriteHeader call. c is a copy
+// of the request context, f must not write to the response.
+func WithOnLateWrite(f func(c *gin.Context, n int)) Option {
+	return func(t *Timeout) {
+		t.onLateWrite = f
+	}
+}
+
+// WithSlowThreshold calls f for requests that finished in time but took at
+// least d, with the time the handler took, an early warning for routes
+// getting close to their timeout
+func WithSlowThreshold(d time.Duration, f func(c *gin.Context, elapsed time.Duration)) Option {
+	return func(t *Timeout) {
+		t.slowThreshold = d
+		t.slow = f
+	}
+}
+
+// WithRejectResponse add gin handler used when a request is rejected
+func WithRejectResponse(h gin.HandlerFunc) Option {
+	return func(t *Timeout) {
+		t.rejectResponse = h
+	}
+}
+
+// Load describes how busy the middleware is when a request arrives
+type Load struct {
+	// InFlight is the number of handler goroutines currently running
+	InFlight int64
+	// Waiting is the number of requests waiting for a concurrency slot
+	Waiting int64
+	// Abandoned is the number of handler goroutines running past their deadline
+	Abandoned int64
+}
+
+// AdmissionFunc reports whether a request should be admitted under the given load
+type AdmissionFunc func(c *gin.Context, load Load) bool
+
+// WithPassthroughContentTypes set the content types streamed to the client
+// without buffering, defaults to text/event-stream. Once streaming started
+// the timeout response can no longer replace the response.
+func WithPassthroughContentTypes(types ...string) Option {
+	return func(t *Timeout) {
+		t.passthrough = make([]string, 0, len(types))
+		for _, typ := range types {
+			t.passthrough = append(t.passthrough, strings.ToLower(typ))
+		}
+	}
+}
+
+// WithIdleTimeout turns the timeout into an idle timeout: every write of the
+// handler restarts it, so a download or stream that keeps producing data is
+// not cut at a fixed wall-clock bound, only once it stalls
+func WithIdleTimeout() Option {
+	return func(t *Timeout) {
+		t.idleTimeout = true
+	}
+}
+
+// WithFlushInterval sends the output buffered so far to the client every d
+// while the handler runs, the response is then committed and a timeout can
+// no longer replace it, but the handler is still bound by its deadline
+func WithFlushInterval(d time.Duration) Option {
+	return func(t *Timeout) {
+		t.flushInterval = d
+	}
+}
+
+// WithReaderStreaming streams responses the handler copies from a reader,
+// e.g. with c.DataFromReader, to the client chunk by chunk instead of
+// buffering them whole. Each chunk may take at most the time left before
+// the deadline; once the request timed out the copy fails with ErrTimeout.
+func WithReaderStreaming() Option {
+	return func(t *Timeout) {
+		t.streamReaders = true
+	}
+}
+
+// WithWriteThrough skips body buffering entirely: the handler writes directly
+// to the client and the timeout response is only sent if the deadline fires
+// before the first byte was written, otherwise the response is cut short and
+// ErrTimeout is still attached to the context
+func WithWriteThrough() Option {
+	return func(t *Timeout) {
+		t.writeThrough = true
+	}
+}
+
+// WithWriterClosedError makes writes after the timeout return ErrWriterClosed
+// instead of silently succeeding, so handlers checking write errors stop early
+func WithWriterClosedError() Option {
+	return func(t *Timeout) {
+		t.writerClosedErr = true
+	}
+}
+
+// WithBufferSize set the initial capacity of response buffers, avoiding
+// repeated growth for endpoints with a known response size
+func WithBufferSize(n int) Option {
+	return func(t *Timeout) {
+		t.bufferSize = n
+	}
+}
+
+// WithRouteBufferSizes set the initial buffer capacity per route full path,
+// overriding WithBufferSize
+func WithRouteBufferSizes(sizes map[string]int) Option {
+	return func(t *Timeout) {
+		t.routeBufferSizes = sizes
+	}
+}
+
+// WithStreamAfter buffers only the first n bytes of a response and streams
+// the rest directly. A timeout firing before n bytes were written still
+// replaces the response, afterwards the response is only cut short.
+func WithStreamAfter(n int) Option {
+	return func(t *Timeout) {
+		t.streamAfter = n
+	}
+}
+
+// WithFlushDeadline bounds the time spent writing the buffered response to a
+// slow client after the handler finished, if the underlying connection supports it
+func WithFlushDeadline(d time.Duration) Option {
+	return func(t *Timeout) {
+		t.flushDeadline = d
+	}
+}
+
+// WithPhaseBudgets sets independent budgets for reading the request body,
+// running the handler and writing the response, a zero read or write budget
+// leaves that phase unbounded. The handler budget starts once the body was read. A read timeout
+// is always answered with the built-in 408 since the client was too slow,
+// TimeoutInfo reports the phase that ran out.
+func WithPhaseBudgets(read, process, write time.Duration) Option {
+	return func(t *Timeout) {
+		t.readTimeout = read
+		t.SetTimeout(process)
+		t.flushDeadline = write
+	}
+}
+
+// WithTimeoutHeader shortens the timeout to the budget the caller sent in
+// the header name, in milliseconds or in grpc-timeout format, so a chain of
+// services shares the deadline of the first one. Send it with Transport.
+func WithTimeoutHeader(name string) Option {
+	return func(t *Timeout) {
+		t.timeoutHeader = name
+	}
+}
+
+// WithSpillToDisk moves response bodies larger than threshold bytes from
+// memory to a temporary file in dir (os.TempDir if empty), the file is
+// streamed to the client on success and removed afterwards. Such a body is
+// neither compressed nor passed to the flush interceptor, which would read
+// it back into memory.
+func WithSpillToDisk(threshold int, dir string) Option {
+	return func(t *Timeout) {
+		t.spillThreshold = threshold
+		t.spillDir = dir
+	}
+}
+
+// WithResponseFunc add a timeout response handler receiving details about the timeout
+func WithResponseFunc(f func(c *gin.Context, info Info)) Option {
+	return func(t *Timeout) {
+		t.SetResponse(func(c *gin.Context) {
+			f(c, infoFrom(c))
+		})
 	}
 }
 
+// WithProblemDetails responds to timeouts with an RFC 9457 application/problem+json body
+func WithProblemDetails(typeURI, title string) Option {
+	return WithResponseFunc(problemResponse(typeURI, title))
+}
+
 func defaultResponse(c *gin.Context) {
-	c.String(http.StatusRequestTimeout, http.StatusText(http.StatusRequestTimeout))
+	code := responseStatus(c)
+	c.Header(CodeHeader, CodeRequestTimeout)
+	c.String(code, textBody(c, code))
+}
+
+// textBody is the plain text body of the built-in responses
+func textBody(c *gin.Context, code int) string {
+	if id := RequestID(c); id != "" {
+		return fmt.Sprintf("%s (request id %s)", http.StatusText(code), id)
+	}
+	return http.StatusText(code)
+}
+
+func defaultRejectResponse(c *gin.Context) {
+	c.Header(CodeHeader, CodeServiceUnavailable)
+	c.String(http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
 }
 
 // Timeout struct
 type Timeout struct {
-	timeout  time.Duration
+	timeout  atomic.Int64
 	handler  gin.HandlerFunc
-	response gin.HandlerFunc
+	response atomic.Pointer[gin.HandlerFunc]
+	status   int
+
+	writerFactory    WriterFactory
+	bufPool          BufferPool
+	bufferSize       int
+	routeBufferSizes map[string]int
+	passthrough      []string
+	streamAfter      int
+	writeThrough     bool
+	streamReaders    bool
+	flushInterval    time.Duration
+	idleTimeout      bool
+	writerClosedErr  bool
+	spillThreshold   int
+	spillDir         string
+	flushDeadline    time.Duration
+	readTimeout      time.Duration
+	timeoutHeader    string
+
+	routeTimeouts map[string]time.Duration
+	routePatterns []routePattern
+	minTimeout    time.Duration
+	maxTimeout    time.Duration
+	policySource  PolicySource
+	policyCache   *policyCache
+	policyOnce    sync.Once
+	skipPaths     []*regexp.Regexp
+	staticFast    bool
+	unbuffered    func(c *gin.Context) bool
+	tierFunc      func(c *gin.Context) string
+	tiers         map[string]time.Duration
+	retries       int
+	retryIf       func(c *gin.Context) bool
+	hedgeAfter    float64
+	sharedKeys    bool
+
+	maxConcurrent     int
+	maxConcurrentWait time.Duration
+	rejectResponse    gin.HandlerFunc
+	admission         AdmissionFunc
+	clock             Clock
+	latency           LatencyDistribution
+	dumpRate          float64
+	timeoutStack      bool
+	trace             bool
+	dump              DumpFunc
+	startTime         func(c *gin.Context) time.Time
+	panicResponses    []panicMapping
+	panicHandler      PanicHandler
+	stackDisabled     bool
+	stackMaxBytes     int
+	stackMaxFrames    int
+	completion        CompletionFunc
+	notifier          func(info CompletionInfo)
+	preservedHeaders  []string
+	stripHopByHop     bool
+	strippedHeaders   []string
+	onLateWrite       func(c *gin.Context, n int)
+	slowThreshold     time.Duration
+	slow              func(c *gin.Context, elapsed time.Duration)
+	clientGone        gin.HandlerFunc
+	fallback          func(c *gin.Context, partial Partial)
+	cache             *StaleCache
+	staleHeader       string
+	flights           *flightGroup
+	async             JobFunc
+	serverTiming      bool
+	etag              bool
+	conditional       bool
+	compression       bool
+	interceptor       func(c *gin.Context, status int, header http.Header, body *bytes.Buffer) error
+	compressMin       int
+	digest            DigestAlgorithm
+	durationHeader    string
+	dryRun            bool
+	enforceRatio      float64
+	startAfterBody    bool
+	hooks             Hooks
+	errorReporter     func(c *gin.Context, err error, stack []byte)
+	requestIDKey      string
+	recorder          Recorder
+	traceID           func(c *gin.Context) string
+	expvarPrefix      string
+	expvars           *expvar.Map
+	stats             *statsCollector
+	recent            *recentTimeouts
+
+	abandonedThreshold int64
+	onAbandoned        func(count int64)
+	alert              *rateAlert
+	spikes             *rateAlert
+	readiness          *readiness
+	profiler           *spikeProfiler
+
+	// optionErr records an invalid option value, reported by the constructors
+	optionErr error
+
+	// nestedOnce limits the nested middleware warning to once per middleware
+	nestedOnce sync.Once
+
+	inFlight           atomic.Int64
+	waiting            atomic.Int64
+	abandoned          atomic.Int64
+	abandonedHighWater atomic.Int64
+
+	trackMu sync.Mutex
+	running int
+	idle    chan struct{}
+	closing bool
 }
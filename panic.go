@@ -0,0 +1,102 @@
+package timeout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PanicMatcher reports whether a value recovered from a handler panic matches
+type PanicMatcher func(recovered any) bool
+
+// PanicIs matches panics whose value equals target, errors are matched with errors.Is
+func PanicIs(target any) PanicMatcher {
+	return func(recovered any) bool {
+		if err, ok := recovered.(error); ok {
+			if targetErr, ok := target.(error); ok {
+				return errors.Is(err, targetErr)
+			}
+		}
+		if recovered == nil || !reflect.TypeOf(recovered).Comparable() {
+			return false
+		}
+		return recovered == target
+	}
+}
+
+// PanicHandler handles a panic recovered from the handler goroutine, it may
+// respond, report the panic or re-panic to hand it to gin's recovery
+type PanicHandler func(c *gin.Context, recovered any, stack []byte)
+
+// recovered is a panic value caught in the handler goroutine with its stack
+type recovered struct {
+	value any
+	stack []byte
+}
+
+type panicMapping struct {
+	match    PanicMatcher
+	response gin.HandlerFunc
+}
+
+// mappedPanicResponse returns the response registered for a recovered value
+func (t *Timeout) mappedPanicResponse(recovered any) gin.HandlerFunc {
+	for _, pr := range t.panicResponses {
+		if pr.match(recovered) {
+			return pr.response
+		}
+	}
+	return nil
+}
+
+// captureStack returns the current goroutine stack within the configured limits,
+// or nil if stack capture is disabled
+func (t *Timeout) captureStack() []byte {
+	if t.stackDisabled {
+		return nil
+	}
+	return truncateStack(debug.Stack(), t.stackMaxBytes, t.stackMaxFrames)
+}
+
+// truncateStack keeps the goroutine header and at most maxFrames frames
+// and maxBytes bytes of a stack, zero meaning no limit
+func truncateStack(stack []byte, maxBytes, maxFrames int) []byte {
+	if maxFrames > 0 {
+		// header line followed by two lines per frame: function and file:line
+		lines := bytes.SplitAfter(stack, []byte("\n"))
+		if n := 1 + 2*maxFrames; len(lines) > n {
+			stack = bytes.Join(lines[:n], nil)
+		}
+	}
+	if maxBytes > 0 && len(stack) > maxBytes {
+		stack = stack[:maxBytes]
+	}
+	return stack
+}
+
+// LogPanic returns a PanicHandler logging the panic and its stack to logger
+// (the standard logger if nil) and responding 500, with the stack in the
+// body only when includeStack is true
+func LogPanic(logger *log.Logger, includeStack bool) PanicHandler {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(c *gin.Context, recovered any, stack []byte) {
+		if id := RequestID(c); id != "" {
+			logger.Printf("panic recovered (request id %s): %v\n%s", id, recovered, stack)
+		} else {
+			logger.Printf("panic recovered: %v\n%s", recovered, stack)
+		}
+		body := http.StatusText(http.StatusInternalServerError)
+		if includeStack {
+			body = fmt.Sprintf("panic: %v\n\n%s", recovered, stack)
+		}
+		c.String(http.StatusInternalServerError, body)
+	}
+}
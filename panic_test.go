@@ -0,0 +1,176 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var errBudgetExceeded = errors.New("budget exceeded")
+
+func TestPanicIs(t *testing.T) {
+	assert.True(t, PanicIs(errBudgetExceeded)(fmt.Errorf("wrapped: %w", errBudgetExceeded)))
+	assert.False(t, PanicIs(errBudgetExceeded)(sql.ErrTxDone))
+	assert.True(t, PanicIs("abort")("abort"))
+	assert.False(t, PanicIs("abort")([]string{"abort"}))
+	assert.False(t, PanicIs("abort")(nil))
+}
+
+func TestPanicResponse(t *testing.T) {
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/:kind", New(
+		WithTimeout(1*time.Second),
+		WithHandler(func(c *gin.Context) {
+			switch c.Param("kind") {
+			case "budget":
+				panic(errBudgetExceeded)
+			case "tx":
+				panic(sql.ErrTxDone)
+			default:
+				panic("boom")
+			}
+		}),
+		WithPanicResponse(PanicIs(errBudgetExceeded), func(c *gin.Context) {
+			c.String(http.StatusTooManyRequests, "budget exceeded")
+		}),
+		WithPanicResponse(PanicIs(sql.ErrTxDone), func(c *gin.Context) {
+			c.String(http.StatusConflict, "conflict")
+		}),
+	))
+
+	for path, code := range map[string]int{
+		"/budget": http.StatusTooManyRequests,
+		"/tx":     http.StatusConflict,
+		"/other":  http.StatusInternalServerError,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, path)
+	}
+}
+
+func TestPanicHandler(t *testing.T) {
+	var value any
+	var stack []byte
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithHandler(panicResponse),
+		WithPanicHandler(func(c *gin.Context, recovered any, s []byte) {
+			value, stack = recovered, s
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal"})
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, `{"error":"internal"}`, w.Body.String())
+	assert.Equal(t, "test", value)
+	assert.Contains(t, string(stack), "panicResponse")
+}
+
+func TestTruncateStack(t *testing.T) {
+	stack := []byte("goroutine 1 [running]:\nmain.a()\n\ta.go:1\nmain.b()\n\tb.go:2\n")
+	assert.Equal(t, stack, truncateStack(stack, 0, 0))
+	assert.Equal(t, "goroutine 1 [running]:\nmain.a()\n\ta.go:1\n", string(truncateStack(stack, 0, 1)))
+	assert.Equal(t, "goroutine", string(truncateStack(stack, 9, 0)))
+}
+
+func TestStackCapture(t *testing.T) {
+	var stack []byte
+	handler := func(c *gin.Context, recovered any, s []byte) {
+		stack = s
+		c.Status(http.StatusInternalServerError)
+	}
+
+	for _, opt := range []Option{WithoutStackCapture(), WithStackLimit(16, 0)} {
+		r := gin.New()
+		r.GET("/", New(
+			WithTimeout(1*time.Second),
+			WithHandler(panicResponse),
+			WithPanicHandler(handler),
+			opt,
+		))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		r.ServeHTTP(w, req)
+		assert.LessOrEqual(t, len(stack), 16)
+	}
+}
+
+func TestLogPanic(t *testing.T) {
+	var logs bytes.Buffer
+	for _, includeStack := range []bool{false, true} {
+		r := gin.New()
+		r.GET("/", New(
+			WithTimeout(1*time.Second),
+			WithHandler(panicResponse),
+			WithPanicHandler(LogPanic(log.New(&logs, "", 0), includeStack)),
+		))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, logs.String(), "panic recovered: test")
+		if includeStack {
+			assert.Contains(t, w.Body.String(), "panicResponse")
+		} else {
+			assert.Equal(t, http.StatusText(http.StatusInternalServerError), w.Body.String())
+		}
+	}
+}
+
+func TestErrorReporter(t *testing.T) {
+	type report struct {
+		err   error
+		stack []byte
+	}
+	var reports []report
+	r := gin.New()
+	r.GET("/:kind", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if c.Param("kind") == "panic" {
+				panic(io.ErrUnexpectedEOF)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}),
+		WithPanicHandler(func(c *gin.Context, p any, stack []byte) {
+			c.Status(http.StatusInternalServerError)
+		}),
+		WithErrorReporter(func(c *gin.Context, err error, stack []byte) {
+			reports = append(reports, report{err, stack})
+		}),
+	))
+
+	for _, path := range []string{"/panic", "/slow"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	assert.Len(t, reports, 2)
+	assert.ErrorIs(t, reports[0].err, io.ErrUnexpectedEOF)
+	assert.NotEmpty(t, reports[0].stack)
+	assert.ErrorIs(t, reports[1].err, ErrTimeout)
+	assert.Nil(t, reports[1].stack)
+}
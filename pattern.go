@@ -0,0 +1,44 @@
+package timeout
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// routePattern is a compiled pattern policy
+type routePattern struct {
+	prefix  string
+	exact   bool
+	timeout time.Duration
+}
+
+// compilePatterns turns patterns such as "/api/*" or "/health" into a list
+// ordered so that the first match is the longest one, exact patterns first
+func compilePatterns(patterns map[string]time.Duration) []routePattern {
+	compiled := make([]routePattern, 0, len(patterns))
+	for pattern, d := range patterns {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		compiled = append(compiled, routePattern{prefix: prefix, exact: !wildcard, timeout: d})
+	}
+	sort.Slice(compiled, func(i, j int) bool {
+		if compiled[i].exact != compiled[j].exact {
+			return compiled[i].exact
+		}
+		if len(compiled[i].prefix) != len(compiled[j].prefix) {
+			return len(compiled[i].prefix) > len(compiled[j].prefix)
+		}
+		return compiled[i].prefix < compiled[j].prefix
+	})
+	return compiled
+}
+
+// matchPattern returns the timeout of the longest pattern matching path
+func matchPattern(patterns []routePattern, path string) (time.Duration, bool) {
+	for _, p := range patterns {
+		if p.exact && path == p.prefix || !p.exact && strings.HasPrefix(path, p.prefix) {
+			return p.timeout, true
+		}
+	}
+	return 0, false
+}
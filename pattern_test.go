@@ -0,0 +1,28 @@
+package timeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutePatterns(t *testing.T) {
+	tm := newTimeout(
+		WithTimeout(time.Second),
+		WithRoutePatterns(map[string]time.Duration{
+			"/api/v1/reports/*": 60 * time.Second,
+			"/api/*":            5 * time.Second,
+			"/api/health":       100 * time.Millisecond,
+			"*":                 2 * time.Second,
+		}),
+	)
+
+	assert.Equal(t, 60*time.Second, tm.routeTimeout("/api/v1/reports/:id"))
+	assert.Equal(t, 5*time.Second, tm.routeTimeout("/api/v1/users"))
+	assert.Equal(t, 100*time.Millisecond, tm.routeTimeout("/api/health"))
+	assert.Equal(t, 2*time.Second, tm.routeTimeout("/static/app.js"))
+
+	tm = newTimeout(WithTimeout(time.Second), WithRoutePatterns(map[string]time.Duration{"/api/*": time.Minute}))
+	assert.Equal(t, time.Second, tm.routeTimeout("/web"))
+}
@@ -0,0 +1,164 @@
+package timeout
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoutePolicy is the effective timeout policy of a single route
+type RoutePolicy struct {
+	Method  string
+	Path    string
+	Timeout time.Duration
+}
+
+// String formats the policy as a stable single line, handy for diffing in CI
+func (p RoutePolicy) String() string {
+	return fmt.Sprintf("%s %s %s", p.Method, p.Path, p.Timeout)
+}
+
+// Policies walks the routes of engine and returns the effective timeout per route
+// for a middleware built with the same options, sorted by path then method.
+func Policies(engine *gin.Engine, opts ...Option) []RoutePolicy {
+	t := newTimeout(opts...)
+
+	routes := engine.Routes()
+	policies := make([]RoutePolicy, 0, len(routes))
+	for _, route := range routes {
+		policies = append(policies, RoutePolicy{
+			Method:  route.Method,
+			Path:    route.Path,
+			Timeout: t.clamp(t.routeTimeout(route.Path)),
+		})
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Path != policies[j].Path {
+			return policies[i].Path < policies[j].Path
+		}
+		return policies[i].Method < policies[j].Method
+	})
+	return policies
+}
+
+// routeTimeout resolves the timeout of a route from the policy source,
+// then the route timeouts, then the route patterns, falling back to the default
+func (t *Timeout) routeTimeout(fullPath string) time.Duration {
+	if t.policySource != nil {
+		var p Policy
+		if t.policyCache != nil {
+			p = t.policyCache.get(fullPath)
+		} else {
+			p = t.policySource.Get(fullPath)
+		}
+		if p.Timeout != 0 {
+			return time.Duration(p.Timeout)
+		}
+	}
+	if d, ok := t.routeTimeouts[fullPath]; ok {
+		return d
+	}
+	if d, ok := matchPattern(t.routePatterns, fullPath); ok {
+		return d
+	}
+	return t.Timeout()
+}
+
+// OverrideKey is the gin context key an earlier middleware can set to a
+// time.Duration to override the timeout of the current request
+const OverrideKey = "github.com/gin-contrib/timeout/override"
+
+// requestTimeout resolves the timeout of the current request, an override
+// set on the context takes precedence over the caller's tier, which takes
+// precedence over the route policies. A shorter deadline already carried by
+// the request context or the timeout header wins, within the bounds set with
+// WithMinTimeout and WithMaxTimeout.
+func (t *Timeout) requestTimeout(c *gin.Context) time.Duration {
+	if v, ok := c.Get(OverrideKey); ok {
+		if d, ok := v.(time.Duration); ok {
+			return t.clamp(t.bound(c, d))
+		}
+	}
+	if d, ok := t.tierTimeout(c); ok {
+		return t.clamp(t.bound(c, d))
+	}
+	return t.clamp(t.bound(c, t.routeTimeout(c.FullPath())))
+}
+
+// bound shortens a timeout to the deadlines imposed by the caller
+func (t *Timeout) bound(c *gin.Context, d time.Duration) time.Duration {
+	return t.headerBound(c, contextBound(c, d))
+}
+
+// tierTimeout returns the timeout of the tier the request belongs to
+func (t *Timeout) tierTimeout(c *gin.Context) (time.Duration, bool) {
+	if t.tierFunc == nil {
+		return 0, false
+	}
+	d, ok := t.tiers[t.tierFunc(c)]
+	return d, ok
+}
+
+// contextBound shortens an enabled timeout to the deadline of the request
+// context, an expired deadline still yields a positive timeout so the
+// handler is cut off instead of running unbounded
+func contextBound(c *gin.Context, d time.Duration) time.Duration {
+	deadline, ok := c.Request.Context().Deadline()
+	if !ok || d <= 0 {
+		return d
+	}
+	if remaining := time.Until(deadline); remaining < d {
+		return max(remaining, time.Nanosecond)
+	}
+	return d
+}
+
+// nestedBound shortens the timeout to the deadline of an enclosing timeout
+// middleware, warning once in debug mode since nesting buffers twice
+func (t *Timeout) nestedBound(c *gin.Context, d time.Duration) time.Duration {
+	remaining, ok := deadlineFrom(c).remaining()
+	if !ok || d <= 0 {
+		return d
+	}
+	t.nestedOnce.Do(func() {
+		if gin.IsDebugging() {
+			fmt.Fprintf(gin.DefaultWriter, "[GIN-debug] [WARNING] timeout middleware nested in another one on %s, "+
+				"the tighter deadline applies but the response is buffered twice\n", c.FullPath())
+		}
+	})
+	if remaining < d {
+		return max(remaining, time.Nanosecond)
+	}
+	return d
+}
+
+// clamp keeps a budget within the configured bounds. A non-positive budget
+// disables the timeout unless a ceiling is set, which then applies instead.
+func (t *Timeout) clamp(d time.Duration) time.Duration {
+	if d <= 0 {
+		if t.maxTimeout > 0 {
+			return t.maxTimeout
+		}
+		return d
+	}
+	if t.minTimeout > 0 && d < t.minTimeout {
+		return t.minTimeout
+	}
+	if t.maxTimeout > 0 && d > t.maxTimeout {
+		return t.maxTimeout
+	}
+	return d
+}
+
+// skipPath reports whether a request path is excluded from the timeout
+func (t *Timeout) skipPath(path string) bool {
+	for _, re := range t.skipPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
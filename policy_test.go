@@ -0,0 +1,249 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicies(t *testing.T) {
+	opts := []Option{
+		WithTimeout(2 * time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithRouteTimeouts(map[string]time.Duration{
+			"/reports/:id": time.Minute,
+		}),
+	}
+
+	r := gin.New()
+	r.Use(New(opts...))
+	r.GET("/users", func(c *gin.Context) {})
+	r.POST("/users", func(c *gin.Context) {})
+	r.GET("/reports/:id", func(c *gin.Context) {})
+
+	policies := Policies(r, opts...)
+	assert.Equal(t, []RoutePolicy{
+		{Method: http.MethodGet, Path: "/reports/:id", Timeout: time.Minute},
+		{Method: http.MethodGet, Path: "/users", Timeout: 2 * time.Second},
+		{Method: http.MethodPost, Path: "/users", Timeout: 2 * time.Second},
+	}, policies)
+	assert.Equal(t, "GET /reports/:id 1m0s", policies[0].String())
+}
+
+func TestRouteTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithRouteTimeouts(map[string]time.Duration{
+			"/slow": 20 * time.Millisecond,
+		}),
+	))
+	r.GET("/slow", func(c *gin.Context) {
+		<-release
+		c.String(http.StatusOK, "")
+	})
+	r.GET("/fast", func(c *gin.Context) {
+		c.String(http.StatusOK, "")
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/slow", nil)
+	start := time.Now()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Less(t, time.Since(start), time.Second)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/fast", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMinTimeout(t *testing.T) {
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(1*time.Second),
+		WithMinTimeout(1*time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithRouteTimeouts(map[string]time.Duration{
+			"/": time.Microsecond,
+		}),
+	))
+	r.GET("/", emptySuccessResponse)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMinTimeoutFloorsHeaderBudget(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithMinTimeout(500*time.Millisecond),
+		WithTimeoutHeader("X-Request-Timeout"),
+		WithHandler(func(c *gin.Context) {
+			remaining, _ := Remaining(c)
+			time.Sleep(20 * time.Millisecond)
+			c.String(http.StatusOK, remaining.String())
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Timeout", "1")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	remaining, err := time.ParseDuration(w.Body.String())
+	assert.NoError(t, err)
+	assert.Greater(t, remaining, 400*time.Millisecond)
+}
+
+func TestMaxTimeout(t *testing.T) {
+	tm := newTimeout(WithMinTimeout(time.Second), WithMaxTimeout(time.Minute))
+	assert.Equal(t, time.Second, tm.clamp(time.Millisecond))
+	assert.Equal(t, 30*time.Second, tm.clamp(30*time.Second))
+	assert.Equal(t, time.Minute, tm.clamp(time.Hour))
+	assert.Equal(t, time.Minute, tm.clamp(0))
+
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(1*time.Second),
+		WithMaxTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithRouteTimeouts(map[string]time.Duration{
+			"/": -1,
+		}),
+	))
+	r.GET("/", emptySuccessResponse)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
+
+func TestOverrideKey(t *testing.T) {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Tenant") == "premium" {
+			c.Set(OverrideKey, time.Second)
+		}
+	})
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(emptySuccessResponse),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	req.Header.Set("X-Tenant", "premium")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSkipPathRegexp(t *testing.T) {
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithSkipPathRegexp("^/static/.*", "^/ws/"),
+	))
+	r.GET("/static/*file", emptySuccessResponse)
+	r.GET("/api", emptySuccessResponse)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/static/app.js", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/api", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+
+	_, err := NewE(WithHandler(emptySuccessResponse), WithSkipPathRegexp("("))
+	assert.ErrorContains(t, err, `invalid skip path pattern "("`)
+	assert.Panics(t, func() { New(WithSkipPathRegexp("(")) })
+}
+
+func TestContextDeadline(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(100 * time.Millisecond)
+			c.Status(http.StatusOK)
+		}),
+	))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	start := time.Now()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestNestedTimeout(t *testing.T) {
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {}),
+	))
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(200 * time.Millisecond)
+			c.Status(http.StatusOK)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	start := time.Now()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestTiers(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(10*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(50 * time.Millisecond)
+			c.Status(http.StatusOK)
+		}),
+		WithTierFunc(func(c *gin.Context) string {
+			return c.GetHeader("X-Tier")
+		}),
+		WithTiers(map[string]time.Duration{"premium": time.Second}),
+	))
+
+	for tier, code := range map[string]int{"": http.StatusRequestTimeout, "premium": http.StatusOK} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tier", tier)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, tier)
+	}
+}
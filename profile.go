@@ -0,0 +1,68 @@
+package timeout
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// Profile holds the profiles captured when the timeout rate spiked
+type Profile struct {
+	// Rate is the overall timeout rate that triggered the capture
+	Rate float64
+	// At is when the capture started
+	At time.Time
+	// Goroutine is a goroutine profile in pprof format
+	Goroutine []byte
+	// CPU is a CPU profile in pprof format, empty if not requested or if
+	// another CPU profile was already running
+	CPU []byte
+}
+
+// ProfileSink receives the profiles captured on a timeout rate spike
+type ProfileSink func(p Profile)
+
+// spikeProfiler captures profiles at most once per cool-down period
+type spikeProfiler struct {
+	mu       sync.Mutex
+	clock    Clock
+	cooldown time.Duration
+	cpu      time.Duration
+	sink     ProfileSink
+	last     time.Time
+}
+
+// trigger starts a capture unless one happened during the cool-down period
+func (p *spikeProfiler) trigger(route string, rate float64) {
+	if route != "" {
+		return
+	}
+	now := p.clock.Now()
+	p.mu.Lock()
+	if !p.last.IsZero() && now.Sub(p.last) < p.cooldown {
+		p.mu.Unlock()
+		return
+	}
+	p.last = now
+	p.mu.Unlock()
+
+	go p.capture(Profile{Rate: rate, At: now})
+}
+
+// capture collects the profiles and hands them to the sink
+func (p *spikeProfiler) capture(prof Profile) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 0); err == nil {
+		prof.Goroutine = buf.Bytes()
+	}
+	if p.cpu > 0 {
+		var cpu bytes.Buffer
+		if err := pprof.StartCPUProfile(&cpu); err == nil {
+			<-p.clock.After(p.cpu)
+			pprof.StopCPUProfile()
+			prof.CPU = cpu.Bytes()
+		}
+	}
+	p.sink(prof)
+}
@@ -0,0 +1,32 @@
+package timeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpikeProfiler(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	profiles := make(chan Profile, 2)
+	p := &spikeProfiler{
+		clock:    clock,
+		cooldown: time.Minute,
+		sink:     func(p Profile) { profiles <- p },
+	}
+
+	p.trigger("", 0.6)
+	prof := <-profiles
+	assert.Equal(t, 0.6, prof.Rate)
+	assert.NotEmpty(t, prof.Goroutine)
+	assert.Empty(t, prof.CPU)
+
+	// per route alerts and alerts during the cool-down are ignored
+	p.trigger("/slow", 0.9)
+	p.trigger("", 0.7)
+	clock.Advance(time.Minute)
+	p.trigger("", 0.8)
+	assert.Equal(t, 0.8, (<-profiles).Rate)
+	assert.Empty(t, profiles)
+}
@@ -0,0 +1,140 @@
+package timeout
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProgressEntry is the latest progress reported by a handler
+type ProgressEntry struct {
+	// Stage names the step the handler reached
+	Stage string
+	// Details carries any step specific data, e.g. the item being processed
+	Details any
+	// At is when the progress was reported
+	At time.Time
+}
+
+// StageTiming is the time a handler spent in an annotated stage
+type StageTiming struct {
+	Stage    string
+	Duration time.Duration
+}
+
+type stageMark struct {
+	stage string
+	at    time.Time
+}
+
+// tracker holds the progress of a request, it is shared by the copies of the
+// context retries and hedged executions run on
+type tracker struct {
+	mu       sync.Mutex
+	progress ProgressEntry
+	reported bool
+	marks    []stageMark
+	// end freezes the stage breakdown once the request timed out
+	end time.Time
+}
+
+// newTracker stores a tracker on the context
+func newTracker(c *gin.Context) {
+	c.Set(progressKey, &tracker{})
+}
+
+// trackerFrom returns the tracker of a request, nil outside of the middleware
+func trackerFrom(c *gin.Context) *tracker {
+	if v, ok := c.Get(progressKey); ok {
+		if tr, ok := v.(*tracker); ok {
+			return tr
+		}
+	}
+	return nil
+}
+
+// Progress records the stage a handler reached, the latest one is included in
+// the Info of a timeout so it is known where the handler got stuck. It is a
+// no-op outside of the middleware.
+func Progress(c *gin.Context, stage string, details any) {
+	tr := trackerFrom(c)
+	if tr == nil {
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.progress = ProgressEntry{Stage: stage, Details: details, At: time.Now()}
+	tr.reported = true
+}
+
+// Annotate marks the start of a stage of the handler, e.g. "db" or "s3", the
+// stage lasts until the next one starts. On timeout the time spent in each
+// stage is available through Stages and reported to a StageRecorder. It is
+// a no-op outside of the middleware.
+func Annotate(c *gin.Context, stage string) {
+	tr := trackerFrom(c)
+	if tr == nil {
+		return
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.end.IsZero() {
+		tr.marks = append(tr.marks, stageMark{stage: stage, at: time.Now()})
+	}
+}
+
+// Stages returns the time spent in each annotated stage, in the order the
+// stages first started, up to the timeout if the request timed out
+func Stages(c *gin.Context) []StageTiming {
+	tr := trackerFrom(c)
+	if tr == nil {
+		return nil
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	end := tr.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return tr.breakdown(end)
+}
+
+// freeze stops the stage breakdown at the timeout and returns it
+func (tr *tracker) freeze() []StageTiming {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.end = time.Now()
+	return tr.breakdown(tr.end)
+}
+
+// breakdown sums the time spent per stage until end, the caller holds the lock
+func (tr *tracker) breakdown(end time.Time) []StageTiming {
+	var stages []StageTiming
+	index := make(map[string]int)
+	for i, m := range tr.marks {
+		next := end
+		if i+1 < len(tr.marks) {
+			next = tr.marks[i+1].at
+		}
+		j, ok := index[m.stage]
+		if !ok {
+			j = len(stages)
+			index[m.stage] = j
+			stages = append(stages, StageTiming{Stage: m.stage})
+		}
+		stages[j].Duration += next.Sub(m.at)
+	}
+	return stages
+}
+
+// LastProgress returns the latest progress reported by the handler
+func LastProgress(c *gin.Context) (ProgressEntry, bool) {
+	tr := trackerFrom(c)
+	if tr == nil {
+		return ProgressEntry{}, false
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.progress, tr.reported
+}
@@ -0,0 +1,86 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	var info Info
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			Progress(c, "load", nil)
+			Progress(c, "render", 3)
+			time.Sleep(50 * time.Millisecond)
+		}),
+		WithResponseFunc(func(c *gin.Context, i Info) {
+			info = i
+			c.Status(http.StatusRequestTimeout)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	if assert.NotNil(t, info.Progress) {
+		assert.Equal(t, "render", info.Progress.Stage)
+		assert.Equal(t, 3, info.Progress.Details)
+	}
+
+	// outside of the middleware progress is dropped
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	Progress(c, "load", nil)
+	_, ok := LastProgress(c)
+	assert.False(t, ok)
+}
+
+type stageRecorder struct {
+	countingRecorder
+	stages map[string]time.Duration
+}
+
+func (r *stageRecorder) ObserveStage(route, stage string, d time.Duration) {
+	r.stages[stage] += d
+}
+
+func TestAnnotate(t *testing.T) {
+	rec := &stageRecorder{
+		countingRecorder: countingRecorder{timeouts: make(map[string]int)},
+		stages:           make(map[string]time.Duration),
+	}
+	var stages []StageTiming
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			Annotate(c, "db")
+			time.Sleep(10 * time.Millisecond)
+			Annotate(c, "s3")
+			time.Sleep(100 * time.Millisecond)
+		}),
+		WithRecorder(rec),
+		WithHooks(Hooks{OnTimeout: func(c *gin.Context) { stages = Stages(c) }}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	if assert.Len(t, stages, 2) {
+		assert.Equal(t, "db", stages[0].Stage)
+		assert.GreaterOrEqual(t, stages[0].Duration, 10*time.Millisecond)
+		assert.Equal(t, "s3", stages[1].Stage)
+		assert.Less(t, stages[1].Duration, 100*time.Millisecond)
+	}
+	assert.Equal(t, stages[1].Duration, rec.stages["s3"])
+}
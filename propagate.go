@@ -0,0 +1,89 @@
+package timeout
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FormatMillis formats a budget as a number of milliseconds, the format of
+// X-Request-Timeout style headers
+func FormatMillis(d time.Duration) string {
+	return strconv.FormatInt(max(d.Milliseconds(), 0), 10)
+}
+
+// FormatGRPCTimeout formats a budget as a grpc-timeout header value
+func FormatGRPCTimeout(d time.Duration) string {
+	d = max(d, 0)
+	// grpc-timeout values have at most 8 digits, use the finest unit that fits
+	for _, u := range []struct {
+		unit string
+		d    time.Duration
+	}{{"n", time.Nanosecond}, {"u", time.Microsecond}, {"m", time.Millisecond}, {"S", time.Second}, {"M", time.Minute}} {
+		if v := d / u.d; v < 1e8 {
+			return strconv.FormatInt(int64(v), 10) + u.unit
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour), 10) + "H"
+}
+
+// maxTimeoutDigits is the longest value grpc-timeout allows, also applied to
+// budgets in milliseconds
+const maxTimeoutDigits = 8
+
+// parseTimeoutHeader parses a positive budget in milliseconds or in
+// grpc-timeout format, rejecting values that are too long or overflow
+func parseTimeoutHeader(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond,
+	}
+	unit, ok := units[v[len(v)-1]]
+	if ok {
+		v = v[:len(v)-1]
+	} else {
+		unit = time.Millisecond
+	}
+	if v == "" || len(v) > maxTimeoutDigits || strings.Trim(v, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// headerBound shortens an enabled timeout to the budget the caller sent in
+// the header set with WithTimeoutHeader
+func (t *Timeout) headerBound(c *gin.Context, d time.Duration) time.Duration {
+	if t.timeoutHeader == "" || d <= 0 {
+		return d
+	}
+	if budget, ok := parseTimeoutHeader(c.GetHeader(t.timeoutHeader)); ok && budget < d {
+		return max(budget, time.Nanosecond)
+	}
+	return d
+}
+
+// TraceParentID returns the trace ID of the W3C traceparent header of the
+// request, empty if it is missing or malformed. Use it with WithTraceID when
+// the tracing middleware does not expose the trace ID otherwise.
+func TraceParentID(c *gin.Context) string {
+	// version "-" trace-id "-" parent-id "-" flags
+	parts := strings.Split(c.GetHeader("traceparent"), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || parts[1] == strings.Repeat("0", 32) {
+		return ""
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,110 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutHeaderFormats(t *testing.T) {
+	assert.Equal(t, "1500", FormatMillis(1500*time.Millisecond))
+	assert.Equal(t, "250000u", FormatGRPCTimeout(250*time.Millisecond))
+	assert.Equal(t, "3000000u", FormatGRPCTimeout(3*time.Second))
+
+	for _, v := range []string{"250", "250m", "250000u"} {
+		d, ok := parseTimeoutHeader(v)
+		assert.True(t, ok, v)
+		assert.Equal(t, 250*time.Millisecond, d, v)
+	}
+	_, ok := parseTimeoutHeader("soon")
+	assert.False(t, ok)
+}
+
+func TestParseTimeoutHeader(t *testing.T) {
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"99999999H", 0, false},
+		{"99999999999H", 0, false},
+		{"999999999", 0, false},
+		{"250000000n", 0, false},
+		{"99999999", 99999999 * time.Millisecond, true},
+		{"2562047H", 2562047 * time.Hour, true},
+		{"2562048H", 0, false},
+		{"0", 0, false},
+		{"0S", 0, false},
+		{"-5m", 0, false},
+		{"+5m", 0, false},
+		{"S", 0, false},
+		{"", 0, false},
+		{"5S", 5 * time.Second, true},
+	}
+	for _, tc := range cases {
+		d, ok := parseTimeoutHeader(tc.value)
+		assert.Equal(t, tc.ok, ok, tc.value)
+		if tc.ok {
+			assert.Equal(t, tc.want, d, tc.value)
+		}
+	}
+}
+
+func TestTimeoutHeaderPropagation(t *testing.T) {
+	downstream := gin.New()
+	downstream.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			remaining, _ := Remaining(c)
+			c.String(http.StatusOK, strconv.FormatInt(remaining.Milliseconds(), 10))
+		}),
+		WithTimeoutHeader("X-Request-Timeout"),
+	))
+	server := httptest.NewServer(downstream)
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Header: "X-Request-Timeout"}}
+	var remaining int
+	upstream := gin.New()
+	upstream.GET("/", New(
+		WithTimeout(200*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			req, _ := http.NewRequestWithContext(c, http.MethodGet, server.URL, nil)
+			resp, err := client.Do(req)
+			if assert.NoError(t, err) {
+				defer resp.Body.Close()
+				var body [16]byte
+				n, _ := resp.Body.Read(body[:])
+				remaining, _ = strconv.Atoi(string(body[:n]))
+			}
+			c.Status(http.StatusOK)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	upstream.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Greater(t, remaining, 100)
+	assert.LessOrEqual(t, remaining, 200)
+}
+
+func TestTraceParentID(t *testing.T) {
+	for header, want := range map[string]string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01": "",
+		"00-not-hex-01": "",
+		"":              "",
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("traceparent", header)
+		assert.Equal(t, want, TraceParentID(c), header)
+	}
+}
@@ -0,0 +1,68 @@
+package timeout
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readiness tracks the overall timeout rate for Ready
+type readiness struct {
+	mu           sync.Mutex
+	width        time.Duration
+	maxRate      float64
+	maxAbandoned int64
+	window       rateWindow
+}
+
+func (r *readiness) observe(timedOut bool, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.window.add(now, r.width, timedOut)
+}
+
+func (r *readiness) rate(now time.Time) (float64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.window.rate(now, r.width)
+}
+
+// Ready reports whether the instance should keep receiving traffic, it
+// returns an error wrapping ErrNotReady once Shutdown was called or while
+// the thresholds set with WithReadiness are crossed
+func (t *Timeout) Ready() error {
+	t.trackMu.Lock()
+	closing := t.closing
+	t.trackMu.Unlock()
+	if closing {
+		return fmt.Errorf("%w: shutting down", ErrNotReady)
+	}
+	if t.readiness == nil {
+		return nil
+	}
+	r := t.readiness
+	if r.maxAbandoned > 0 {
+		if n := t.Abandoned(); n >= r.maxAbandoned {
+			return fmt.Errorf("%w: %d abandoned handlers", ErrNotReady, n)
+		}
+	}
+	if rate, n := r.rate(t.clock.Now()); n >= alertMinRequests && rate >= r.maxRate {
+		return fmt.Errorf("%w: timeout rate %.2f", ErrNotReady, rate)
+	}
+	return nil
+}
+
+// ReadyHandler returns a handler for a readiness probe such as /readyz, it
+// answers 200 while Ready reports no error and 503 with the reason otherwise
+func (t *Timeout) ReadyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := t.Ready(); err != nil {
+			c.String(http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	}
+}
@@ -0,0 +1,64 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReady(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	tm := NewTimeout(WithClock(clock), WithReadiness(10*time.Second, 0.5, 0))
+	r := gin.New()
+	r.GET("/readyz", tm.ReadyHandler())
+
+	// too few requests to tell
+	tm.readiness.observe(true, clock.Now())
+	assert.NoError(t, tm.Ready())
+
+	for i := 0; i < 10; i++ {
+		tm.readiness.observe(i%2 == 0, clock.Now())
+	}
+	assert.ErrorIs(t, tm.Ready(), ErrNotReady)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "timeout rate")
+
+	// the timeouts left the window
+	clock.Advance(time.Minute)
+	assert.NoError(t, tm.Ready())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	assert.NoError(t, tm.Shutdown(context.Background()))
+	assert.ErrorIs(t, tm.Ready(), ErrNotReady)
+}
+
+func TestReadyDryRun(t *testing.T) {
+	tm := NewTimeout(
+		WithTimeout(5*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(20 * time.Millisecond)
+			c.Status(http.StatusOK)
+		}),
+		WithDryRun(true),
+		WithReadiness(time.Minute, 0.5, 0),
+	)
+	r := gin.New()
+	r.GET("/", tm.Middleware())
+
+	for i := 0; i < alertMinRequests; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	// the shadow timeouts count although the requests succeeded
+	assert.ErrorIs(t, tm.Ready(), ErrNotReady)
+}
@@ -0,0 +1,119 @@
+package timeout
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redacted replaces the values of sensitive headers in recorded requests
+const redacted = "[redacted]"
+
+// sensitiveHeaders are never kept verbatim by WithRecentTimeouts
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// TimedOutRequest is a request recorded by WithRecentTimeouts
+type TimedOutRequest struct {
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	FullPath  string        `json:"full_path"`
+	RequestID string        `json:"request_id,omitempty"`
+	Header    http.Header   `json:"header"`
+	Phase     Phase         `json:"phase"`
+	Timeout   time.Duration `json:"timeout"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Stages    []StageTiming `json:"stages,omitempty"`
+}
+
+// recentTimeouts keeps the last timed out requests in a ring
+type recentTimeouts struct {
+	mu   sync.Mutex
+	ring []TimedOutRequest
+	next int
+	full bool
+}
+
+func newRecentTimeouts(n int) *recentTimeouts {
+	return &recentTimeouts{ring: make([]TimedOutRequest, n)}
+}
+
+func (r *recentTimeouts) add(req TimedOutRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ring[r.next] = req
+	r.next = (r.next + 1) % len(r.ring)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the recorded requests, newest first
+func (r *recentTimeouts) list() []TimedOutRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.ring)
+	}
+	out := make([]TimedOutRequest, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, r.ring[(r.next-i+len(r.ring))%len(r.ring)])
+	}
+	return out
+}
+
+// sanitizeHeader copies h with the values of sensitive headers redacted
+func sanitizeHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := out[name]; ok {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// recordTimeout adds the timed out request to the recent timeouts, if kept
+func (t *Timeout) recordTimeout(c *gin.Context, info Info) {
+	if t.recent == nil {
+		return
+	}
+	t.recent.add(TimedOutRequest{
+		Time:      t.clock.Now(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		FullPath:  info.FullPath,
+		RequestID: info.RequestID,
+		Header:    sanitizeHeader(c.Request.Header),
+		Phase:     info.Phase,
+		Timeout:   info.Timeout,
+		Elapsed:   info.Elapsed,
+		Stages:    info.Stages,
+	})
+}
+
+// RecentTimeouts returns the last requests that timed out, newest first,
+// empty unless WithRecentTimeouts is set
+func (t *Timeout) RecentTimeouts() []TimedOutRequest {
+	if t.recent == nil {
+		return []TimedOutRequest{}
+	}
+	return t.recent.list()
+}
+
+// RecentTimeoutsHandler returns a handler serving RecentTimeouts as JSON, meant
+// to be mounted on an internal debug route
+func (t *Timeout) RecentTimeoutsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, t.RecentTimeouts())
+	}
+}
@@ -0,0 +1,54 @@
+package timeout
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentTimeouts(t *testing.T) {
+	tm := NewTimeout(
+		WithTimeout(10*time.Millisecond),
+		WithRecentTimeouts(2),
+		WithHandler(func(c *gin.Context) {
+			Annotate(c, "query")
+			time.Sleep(30 * time.Millisecond)
+		}),
+	)
+	r := gin.New()
+	r.GET("/slow/:id", tm.Middleware())
+	r.GET("/debug/timeouts", tm.RecentTimeoutsHandler())
+
+	for _, id := range []string{"1", "2", "3"} {
+		req := httptest.NewRequest(http.MethodGet, "/slow/"+id, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Accept", "application/json")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/timeouts", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var recent []TimedOutRequest
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &recent))
+	if assert.Len(t, recent, 2) {
+		assert.Equal(t, "/slow/3", recent[0].Path)
+		assert.Equal(t, "/slow/2", recent[1].Path)
+		assert.Equal(t, "/slow/:id", recent[0].FullPath)
+		assert.Equal(t, http.MethodGet, recent[0].Method)
+		assert.Equal(t, redacted, recent[0].Header.Get("Authorization"))
+		assert.Equal(t, "application/json", recent[0].Header.Get("Accept"))
+		assert.Equal(t, 10*time.Millisecond, recent[0].Timeout)
+		if assert.Len(t, recent[0].Stages, 1) {
+			assert.Equal(t, "query", recent[0].Stages[0].Stage)
+		}
+	}
+
+	assert.Empty(t, NewTimeout().RecentTimeouts())
+}
@@ -0,0 +1,133 @@
+package timeout
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stable machine readable codes carried by the built-in responses,
+// CodeRequestTimeout by the timeout responses whatever their status and
+// CodeServiceUnavailable by the response to rejected requests
+const (
+	CodeRequestTimeout     = "REQUEST_TIMEOUT"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+)
+
+// CodeHeader carries the machine readable code of the plain text built-in
+// responses, the structured ones have it in their body
+const CodeHeader = "X-Error-Code"
+
+// ErrorBody is the data model of the built-in structured responses, Code is
+// stable so clients can branch on it while Message is meant for humans
+type ErrorBody struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Code    string   `json:"code" xml:"code"`
+	Message string   `json:"message" xml:"message"`
+	// RequestID is the correlation ID set with WithRequestID
+	RequestID string `json:"request_id,omitempty" xml:"request_id,omitempty"`
+}
+
+// StatusClientClosedRequest is the non-standard status nginx logs when the
+// client closed the connection before the response was sent
+const StatusClientClosedRequest = 499
+
+// ClientGoneResponse records StatusClientClosedRequest for loggers without
+// writing anything, there is nobody left to read it
+func ClientGoneResponse(c *gin.Context) {
+	c.Status(StatusClientClosedRequest)
+}
+
+// partialResponse returns a fallback sending the partial response followed by marker
+func partialResponse(marker string) func(c *gin.Context, partial Partial) {
+	return func(c *gin.Context, partial Partial) {
+		dst := c.Writer.Header()
+		for k, vv := range partial.Header {
+			dst[k] = vv
+		}
+		// the body no longer matches a length set by the handler
+		dst.Del("Content-Length")
+		status := partial.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		c.Status(status)
+		_, _ = c.Writer.Write(partial.Body)
+		_, _ = c.Writer.WriteString(marker)
+	}
+}
+
+// JSONResponse is a timeout response writing an ErrorBody as JSON
+func JSONResponse(c *gin.Context) {
+	c.JSON(responseStatus(c), timeoutBody(c))
+}
+
+// XMLResponse is a timeout response writing an ErrorBody as XML
+func XMLResponse(c *gin.Context) {
+	c.XML(responseStatus(c), timeoutBody(c))
+}
+
+// responseStatus returns the status of the built-in timeout responses, see WithStatus
+func responseStatus(c *gin.Context) int {
+	if status := infoFrom(c).Status; status != 0 {
+		return status
+	}
+	return http.StatusRequestTimeout
+}
+
+// NegotiatedResponse is a timeout response choosing the body format from the
+// Accept header: JSON or XML ErrorBody for API clients, plain text otherwise
+func NegotiatedResponse(c *gin.Context) {
+	switch c.NegotiateFormat(gin.MIMEPlain, gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2) {
+	case gin.MIMEJSON:
+		JSONResponse(c)
+	case gin.MIMEXML, gin.MIMEXML2:
+		XMLResponse(c)
+	default:
+		defaultResponse(c)
+	}
+}
+
+func timeoutBody(c *gin.Context) ErrorBody {
+	return ErrorBody{
+		Code:      CodeRequestTimeout,
+		Message:   http.StatusText(responseStatus(c)),
+		RequestID: RequestID(c),
+	}
+}
+
+// ProblemDetails is an RFC 9457 problem details body, Code carries the same
+// stable machine readable code as ErrorBody
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// RequestID is the correlation ID set with WithRequestID
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// problemResponse returns a timeout response writing application/problem+json
+func problemResponse(typeURI, title string) func(c *gin.Context, info Info) {
+	return func(c *gin.Context, info Info) {
+		status := responseStatus(c)
+		title := title
+		if title == "" {
+			title = http.StatusText(status)
+		}
+		c.Header("Content-Type", "application/problem+json")
+		c.JSON(status, ProblemDetails{
+			Type:      typeURI,
+			Title:     title,
+			Status:    status,
+			Detail:    fmt.Sprintf("the request did not complete within %s (elapsed %s)", info.Timeout, info.Elapsed),
+			Instance:  c.Request.URL.Path,
+			Code:      CodeRequestTimeout,
+			RequestID: info.RequestID,
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructuredResponses(t *testing.T) {
+	cases := []struct {
+		response gin.HandlerFunc
+		body     string
+	}{
+		{JSONResponse, `{"code":"REQUEST_TIMEOUT","message":"Request Timeout"}`},
+		{XMLResponse, `<error><code>REQUEST_TIMEOUT</code><message>Request Timeout</message></error>`},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.GET("/", New(
+			WithTimeout(50*time.Microsecond),
+			WithHandler(emptySuccessResponse),
+			WithResponse(tc.response),
+		))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestTimeout, w.Code)
+		assert.Equal(t, tc.body, w.Body.String())
+	}
+}
+
+func TestTextResponseCode(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithMaxConcurrent(1),
+		WithHandler(func(c *gin.Context) {
+			started <- struct{}{}
+			<-release
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, CodeRequestTimeout, w.Header().Get(CodeHeader))
+	<-started
+
+	// the abandoned handler still holds the only slot
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, CodeServiceUnavailable, w.Header().Get(CodeHeader))
+}
+
+func TestProblemDetails(t *testing.T) {
+	r := gin.New()
+	r.GET("/reports/:id", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(emptySuccessResponse),
+		WithProblemDetails("https://example.com/problems/timeout", ""),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/reports/7", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var body ProblemDetails
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "https://example.com/problems/timeout", body.Type)
+	assert.Equal(t, "Request Timeout", body.Title)
+	assert.Equal(t, http.StatusRequestTimeout, body.Status)
+	assert.Equal(t, "/reports/7", body.Instance)
+	assert.Equal(t, CodeRequestTimeout, body.Code)
+	assert.Contains(t, body.Detail, "within 50µs")
+}
+
+func TestStructuredResponseStatus(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	cases := []struct {
+		option Option
+		title  string
+	}{
+		{WithResponse(JSONResponse), ""},
+		{WithResponse(XMLResponse), ""},
+		{WithProblemDetails("https://example.com/problems/timeout", ""), "Gateway Timeout"},
+	}
+	for _, tc := range cases {
+		r := gin.New()
+		r.GET("/", New(
+			WithTimeout(20*time.Millisecond),
+			WithHandler(func(c *gin.Context) { <-release }),
+			WithStatus(http.StatusGatewayTimeout),
+			tc.option,
+		))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "Gateway Timeout")
+		if tc.title != "" {
+			var body ProblemDetails
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tc.title, body.Title)
+			assert.Equal(t, http.StatusGatewayTimeout, body.Status)
+		}
+	}
+}
+
+func TestNegotiatedResponse(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(emptySuccessResponse),
+		WithResponse(NegotiatedResponse),
+	))
+
+	for accept, contentType := range map[string]string{
+		"":                                "text/plain; charset=utf-8",
+		"text/html,*/*;q=0.8":             "text/plain; charset=utf-8",
+		"application/json":                "application/json; charset=utf-8",
+		"application/xml;q=0.9":           "application/xml; charset=utf-8",
+		"text/xml, application/json;q=.5": "application/xml; charset=utf-8",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestTimeout, w.Code, accept)
+		assert.Equal(t, contentType, w.Header().Get("Content-Type"), accept)
+	}
+}
+
+func TestPartialFlush(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			c.Header("Content-Type", "text/csv")
+			_, _ = c.Writer.WriteString("a,b\n1,2\n")
+			time.Sleep(100 * time.Millisecond)
+		}),
+		WithPartialFlush("# truncated\n"),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "a,b\n1,2\n# truncated\n", w.Body.String())
+}
@@ -0,0 +1,47 @@
+package timeout
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SharedKeys is a key store shared by the request context and the copies of
+// it that retries and hedged executions run on, so values set by any of them,
+// including a handler still running after the timeout, are visible to all.
+// It is safe for concurrent use, the values themselves are not protected.
+type SharedKeys struct {
+	mu   sync.RWMutex
+	keys map[string]any
+}
+
+// Set stores a value
+func (s *SharedKeys) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		s.keys = make(map[string]any)
+	}
+	s.keys[key] = value
+}
+
+// Get returns a value and whether it was set
+func (s *SharedKeys) Get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.keys[key]
+	return v, ok
+}
+
+// Shared returns the key store of the request set up by WithSharedKeys. Outside
+// of such a middleware it returns a store local to c.
+func Shared(c *gin.Context) *SharedKeys {
+	if v, ok := c.Get(sharedKey); ok {
+		if s, ok := v.(*SharedKeys); ok {
+			return s
+		}
+	}
+	s := &SharedKeys{}
+	c.Set(sharedKey, s)
+	return s
+}
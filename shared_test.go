@@ -0,0 +1,45 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedKeys(t *testing.T) {
+	var calls atomic.Int32
+	var first, second any
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		first, _ = Shared(c).Get("first")
+		second, _ = Shared(c).Get("second")
+	})
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				Shared(c).Set("first", "abandoned attempt")
+				time.Sleep(100 * time.Millisecond)
+				return
+			}
+			Shared(c).Set("second", "retry")
+			c.Status(http.StatusOK)
+		}),
+		WithRetry(1, nil),
+		WithSharedKeys(),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "abandoned attempt", first)
+	assert.Equal(t, "retry", second)
+}
@@ -0,0 +1,65 @@
+package timeout
+
+import (
+	"context"
+)
+
+// track registers a new handler goroutine, it reports false once Shutdown was called
+func (t *Timeout) track() bool {
+	t.trackMu.Lock()
+	defer t.trackMu.Unlock()
+
+	if t.closing {
+		return false
+	}
+	if t.running == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.running++
+	return true
+}
+
+// untrack marks a handler goroutine as finished
+func (t *Timeout) untrack() {
+	t.trackMu.Lock()
+	defer t.trackMu.Unlock()
+
+	t.running--
+	if t.running == 0 {
+		close(t.idle)
+	}
+}
+
+// idleChan returns a channel closed once no handler goroutine is running
+func (t *Timeout) idleChan() <-chan struct{} {
+	t.trackMu.Lock()
+	defer t.trackMu.Unlock()
+
+	if t.running == 0 {
+		ch := make(chan struct{})
+		close(ch)
+		return ch
+	}
+	return t.idle
+}
+
+// Wait blocks until every handler goroutine, including those abandoned
+// after a timeout, has returned
+func (t *Timeout) Wait() {
+	<-t.idleChan()
+}
+
+// Shutdown stops admitting new requests, which get the reject response, and
+// waits for running and abandoned handler goroutines to return or ctx to be done
+func (t *Timeout) Shutdown(ctx context.Context) error {
+	t.trackMu.Lock()
+	t.closing = true
+	t.trackMu.Unlock()
+
+	select {
+	case <-t.idleChan():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
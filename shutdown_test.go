@@ -0,0 +1,45 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	tm := NewTimeout(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) {
+			<-release
+			close(finished)
+		}),
+	)
+	r := gin.New()
+	r.GET("/", tm.Middleware())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tm.Shutdown(ctx), context.DeadlineExceeded)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	close(release)
+	assert.NoError(t, tm.Shutdown(context.Background()))
+	tm.Wait()
+	<-finished
+}
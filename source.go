@@ -0,0 +1,108 @@
+package timeout
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Policy is the timeout policy of a route provided by a PolicySource
+type Policy struct {
+	// Timeout is the budget of the route, zero falls back to the middleware configuration
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+}
+
+// PolicySource provides route policies that can change while serving,
+// for example backed by a central config service shared by a fleet
+type PolicySource interface {
+	// Get returns the policy of a route full path, the zero Policy if none is set
+	Get(route string) Policy
+	// Watch registers f to be called after the policies changed, the
+	// middleware keeps the policies it fetched until then
+	Watch(f func())
+}
+
+// MemoryPolicySource is an in-memory PolicySource safe for concurrent use
+type MemoryPolicySource struct {
+	mu       sync.RWMutex
+	policies map[string]Policy
+	watchers []func()
+}
+
+// NewMemoryPolicySource will return a MemoryPolicySource holding policies
+func NewMemoryPolicySource(policies map[string]Policy) *MemoryPolicySource {
+	s := &MemoryPolicySource{policies: make(map[string]Policy, len(policies))}
+	for route, p := range policies {
+		s.policies[route] = p
+	}
+	return s
+}
+
+// Get returns the policy of a route
+func (s *MemoryPolicySource) Get(route string) Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policies[route]
+}
+
+// Watch registers f to be called after the policies changed
+func (s *MemoryPolicySource) Watch(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers = append(s.watchers, f)
+}
+
+// Set changes the policy of a route and notifies the watchers
+func (s *MemoryPolicySource) Set(route string, p Policy) {
+	s.mu.Lock()
+	s.policies[route] = p
+	s.mu.Unlock()
+	s.notify()
+}
+
+// Replace swaps all policies at once and notifies the watchers
+func (s *MemoryPolicySource) Replace(policies map[string]Policy) {
+	s.mu.Lock()
+	s.policies = make(map[string]Policy, len(policies))
+	for route, p := range policies {
+		s.policies[route] = p
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *MemoryPolicySource) notify() {
+	s.mu.RLock()
+	watchers := append([]func(){}, s.watchers...)
+	s.mu.RUnlock()
+	for _, f := range watchers {
+		f()
+	}
+}
+
+// policyCache keeps the policies fetched from a PolicySource per route,
+// they are all dropped when the source reports a change
+type policyCache struct {
+	src      PolicySource
+	policies atomic.Pointer[sync.Map]
+}
+
+func newPolicyCache(src PolicySource) *policyCache {
+	pc := &policyCache{src: src}
+	pc.reset()
+	src.Watch(pc.reset)
+	return pc
+}
+
+func (pc *policyCache) get(route string) Policy {
+	policies := pc.policies.Load()
+	if p, ok := policies.Load(route); ok {
+		return p.(Policy)
+	}
+	p := pc.src.Get(route)
+	policies.Store(route, p)
+	return p
+}
+
+func (pc *policyCache) reset() {
+	pc.policies.Store(&sync.Map{})
+}
@@ -0,0 +1,97 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPolicySource(t *testing.T) {
+	src := NewMemoryPolicySource(map[string]Policy{"/a": {Timeout: Duration(time.Second)}})
+	notified := 0
+	src.Watch(func() { notified++ })
+
+	assert.Equal(t, Policy{Timeout: Duration(time.Second)}, src.Get("/a"))
+	assert.Equal(t, Policy{}, src.Get("/b"))
+
+	src.Set("/b", Policy{Timeout: Duration(time.Minute)})
+	assert.Equal(t, Policy{Timeout: Duration(time.Minute)}, src.Get("/b"))
+
+	src.Replace(map[string]Policy{})
+	assert.Equal(t, Policy{}, src.Get("/a"))
+	assert.Equal(t, 2, notified)
+}
+
+func TestPolicySource(t *testing.T) {
+	src := NewMemoryPolicySource(nil)
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(1*time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithPolicySource(src),
+	))
+	r.GET("/", emptySuccessResponse)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	src.Set("/", Policy{Timeout: Duration(50 * time.Microsecond)})
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
+
+type countingSource struct {
+	*MemoryPolicySource
+	gets atomic.Int64
+}
+
+func (s *countingSource) Get(route string) Policy {
+	s.gets.Add(1)
+	return s.MemoryPolicySource.Get(route)
+}
+
+func TestPolicySourceWatch(t *testing.T) {
+	src := &countingSource{MemoryPolicySource: NewMemoryPolicySource(nil)}
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithPolicySource(src),
+	))
+	r.GET("/", emptySuccessResponse)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, int64(1), src.gets.Load())
+
+	src.Set("/", Policy{Timeout: Duration(20 * time.Millisecond)})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2), src.gets.Load())
+}
+
+func TestPolicyJSON(t *testing.T) {
+	var p Policy
+	assert.NoError(t, json.Unmarshal([]byte(`{"timeout":"1.5s"}`), &p))
+	assert.Equal(t, Duration(1500*time.Millisecond), p.Timeout)
+
+	b, err := json.Marshal(Policy{Timeout: Duration(time.Second)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"timeout":"1s"}`, string(b))
+}
@@ -0,0 +1,98 @@
+package timeout
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// buffer appends data to the response body, moving it to a temporary file
+// once it grows beyond the spill threshold
+func (w *Writer) buffer(data []byte) (int, error) {
+	if w.spill == nil && w.spillThreshold > 0 && w.body.Len()+len(data) > w.spillThreshold {
+		if err := w.spillToDisk(); err != nil {
+			return 0, err
+		}
+	}
+	if w.spill != nil {
+		return w.spill.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+// spillToDisk moves the in-memory body to a new temporary file
+func (w *Writer) spillToDisk() error {
+	f, err := os.CreateTemp(w.spillDir, "gin-timeout-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(w.body.Bytes()); err != nil {
+		w.spill = f
+		w.closeSpill()
+		return err
+	}
+	w.body.Reset()
+	w.spill = f
+	return nil
+}
+
+// writeBuffered writes the buffered body, in memory or spilled, to the underlying writer
+func (w *Writer) writeBuffered() error {
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		return err
+	}
+	if w.spill == nil {
+		return nil
+	}
+	if _, err := w.spill.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.ResponseWriter, w.spill)
+	return err
+}
+
+// readBody lets f read the buffered body, a spilled body is streamed from
+// its file. It reports false without calling f when there is no buffered body.
+func (w *Writer) readBody(f func(r io.Reader) error) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.body == nil || w.streaming {
+		return false, nil
+	}
+	r := io.Reader(bytes.NewReader(w.body.Bytes()))
+	if w.spill != nil {
+		info, err := w.spill.Stat()
+		if err != nil {
+			return true, err
+		}
+		r = io.MultiReader(r, io.NewSectionReader(w.spill, 0, info.Size()))
+	}
+	return true, f(r)
+}
+
+// bufferedBytes returns a copy of the buffered body, in memory or spilled
+func (w *Writer) bufferedBytes() []byte {
+	if w.body == nil {
+		return nil
+	}
+	body := append([]byte(nil), w.body.Bytes()...)
+	if w.spill == nil {
+		return body
+	}
+	if info, err := w.spill.Stat(); err == nil {
+		spilled, _ := io.ReadAll(io.NewSectionReader(w.spill, 0, info.Size()))
+		body = append(body, spilled...)
+	}
+	return body
+}
+
+// closeSpill closes and removes the spill file if any
+func (w *Writer) closeSpill() {
+	if w.spill == nil {
+		return
+	}
+	_ = w.spill.Close()
+	_ = os.Remove(w.spill.Name())
+	w.spill = nil
+}
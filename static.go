@@ -0,0 +1,42 @@
+package timeout
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginStaticHandlers are the name prefixes of the handlers gin registers for
+// Static, StaticFS, StaticFile and StaticFileFS. They are internal to gin and
+// checked against the gin in use by TestStaticRouteGinHandlers.
+var ginStaticHandlers = []string{
+	"github.com/gin-gonic/gin.(*RouterGroup).createStaticHandler.",
+	"github.com/gin-gonic/gin.(*RouterGroup).StaticFile.",
+	"github.com/gin-gonic/gin.(*RouterGroup).StaticFileFS.",
+}
+
+// StaticRoute reports whether the request is served by a route registered
+// with one of gin's Static helpers. It recognizes them by the names of their
+// handlers, which may change with a gin release, so prefer matching your
+// static routes by path where you can.
+func StaticRoute(c *gin.Context) bool {
+	name := c.HandlerName()
+	for _, prefix := range ginStaticHandlers {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fastPath reports whether the request bypasses the buffering, see
+// WithStaticFastPath
+func (t *Timeout) fastPath(c *gin.Context) bool {
+	if !t.staticFast {
+		return false
+	}
+	if t.unbuffered != nil {
+		return t.unbuffered(c)
+	}
+	return StaticRoute(c)
+}
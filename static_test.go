@@ -0,0 +1,115 @@
+package timeout
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStaticFastPath(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("app"), 0o600))
+
+	var buffered bool
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithStaticFastPath(func(c *gin.Context) bool {
+			return StaticRoute(c) || c.FullPath() == "/download"
+		}),
+	))
+	r.Use(func(c *gin.Context) {
+		_, buffered = c.Writer.(*Writer)
+		c.Next()
+	})
+	r.Static("/assets", dir)
+	r.StaticFile("/app.js", filepath.Join(dir, "app.js"))
+	r.GET("/download", func(c *gin.Context) { c.String(http.StatusOK, "app") })
+	r.GET("/api", func(c *gin.Context) { c.String(http.StatusOK, "app") })
+
+	for path, wantBuffered := range map[string]bool{
+		"/assets/app.js": false,
+		"/app.js":        false,
+		"/download":      false,
+		"/api":           true,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "app", w.Body.String(), path)
+		assert.Equal(t, wantBuffered, buffered, path)
+	}
+}
+
+func TestStaticFastPathMatcher(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("app"), 0o600))
+
+	var buffered bool
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithStaticFastPath(func(c *gin.Context) bool { return c.FullPath() == "/download" }),
+	))
+	r.Use(func(c *gin.Context) {
+		_, buffered = c.Writer.(*Writer)
+		c.Next()
+	})
+	r.StaticFile("/app.js", filepath.Join(dir, "app.js"))
+	r.GET("/download", func(c *gin.Context) { c.String(http.StatusOK, "app") })
+
+	// the matcher alone decides, gin's static routes are not added to it
+	for path, wantBuffered := range map[string]bool{
+		"/app.js":   true,
+		"/download": false,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, wantBuffered, buffered, path)
+	}
+}
+
+//go:embed static.go
+var staticFS embed.FS
+
+// TestStaticRouteGinHandlers checks the gin handler names StaticRoute relies on,
+// update ginStaticHandlers when it fails after a gin upgrade
+func TestStaticRouteGinHandlers(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("app"), 0o600))
+
+	var static bool
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		static = StaticRoute(c)
+		c.Next()
+	})
+	r.Static("/static", dir)
+	r.StaticFS("/fs", http.Dir(dir))
+	r.StaticFile("/file.js", filepath.Join(dir, "app.js"))
+	r.StaticFileFS("/embedded.go", "static.go", http.FS(staticFS))
+	r.GET("/api", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for path, want := range map[string]bool{
+		"/static/app.js": true,
+		"/fs/app.js":     true,
+		"/file.js":       true,
+		"/embedded.go":   true,
+		"/api":           false,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, want, static, path)
+	}
+}
@@ -0,0 +1,130 @@
+package timeout
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// statsWindow is the number of recent durations kept per route for percentiles
+const statsWindow = 1024
+
+// RouteStats holds the counters of a single route
+type RouteStats struct {
+	Count      int64         `json:"count"`
+	Timeouts   int64         `json:"timeouts"`
+	Panics     int64         `json:"panics"`
+	ClientGone int64         `json:"client_gone"`
+	P50        time.Duration `json:"p50"`
+	P95        time.Duration `json:"p95"`
+}
+
+// Stats holds the totals and the per route breakdown, keyed by full path
+type Stats struct {
+	RouteStats
+	Routes map[string]RouteStats `json:"routes"`
+}
+
+type routeStats struct {
+	count    int64
+	timeouts int64
+	panics   int64
+	gone     int64
+	samples  []time.Duration
+	next     int
+}
+
+func (s *routeStats) observe(d time.Duration) {
+	s.count++
+	if len(s.samples) < statsWindow {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % statsWindow
+}
+
+func (s *routeStats) snapshot() RouteStats {
+	rs := RouteStats{Count: s.count, Timeouts: s.timeouts, Panics: s.panics, ClientGone: s.gone}
+	if len(s.samples) == 0 {
+		return rs
+	}
+	sorted := append([]time.Duration(nil), s.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rs.P50 = percentile(sorted, 0.50)
+	rs.P95 = percentile(sorted, 0.95)
+	return rs
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// statsCollector aggregates per route statistics
+type statsCollector struct {
+	mu     sync.Mutex
+	total  routeStats
+	routes map[string]*routeStats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{routes: make(map[string]*routeStats)}
+}
+
+// route returns the stats of a route, the caller holds the lock
+func (s *statsCollector) route(path string) *routeStats {
+	rs, ok := s.routes[path]
+	if !ok {
+		rs = &routeStats{}
+		s.routes[path] = rs
+	}
+	return rs
+}
+
+func (s *statsCollector) observe(path string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total.observe(d)
+	s.route(path).observe(d)
+}
+
+func (s *statsCollector) incTimeout(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total.timeouts++
+	s.route(path).timeouts++
+}
+
+func (s *statsCollector) incPanic(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total.panics++
+	s.route(path).panics++
+}
+
+func (s *statsCollector) incClientGone(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total.gone++
+	s.route(path).gone++
+}
+
+// Stats returns the statistics collected since WithStats was enabled,
+// the zero Stats if it was not
+func (t *Timeout) Stats() Stats {
+	if t.stats == nil {
+		return Stats{}
+	}
+	s := t.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := Stats{
+		RouteStats: s.total.snapshot(),
+		Routes:     make(map[string]RouteStats, len(s.routes)),
+	}
+	for path, rs := range s.routes {
+		stats.Routes[path] = rs.snapshot()
+	}
+	return stats
+}
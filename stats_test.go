@@ -0,0 +1,57 @@
+package timeout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	rs := &routeStats{}
+	for i := 1; i <= 100; i++ {
+		rs.observe(time.Duration(i) * time.Millisecond)
+	}
+	snap := rs.snapshot()
+	assert.Equal(t, int64(100), snap.Count)
+	assert.Equal(t, 50*time.Millisecond, snap.P50)
+	assert.Equal(t, 95*time.Millisecond, snap.P95)
+
+	for i := 0; i < 2*statsWindow; i++ {
+		rs.observe(time.Second)
+	}
+	assert.Len(t, rs.samples, statsWindow)
+	assert.Equal(t, time.Second, rs.snapshot().P50)
+}
+
+func TestStats(t *testing.T) {
+	tm := NewTimeout(
+		WithTimeout(50*time.Millisecond),
+		WithStats(),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+	)
+	r := gin.New()
+	r.Use(tm.Middleware())
+	r.GET("/fast", func(c *gin.Context) {})
+	r.GET("/slow", func(c *gin.Context) { time.Sleep(100 * time.Millisecond) })
+
+	for _, path := range []string{"/fast", "/fast", "/slow"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	stats := tm.Stats()
+	assert.Equal(t, int64(3), stats.Count)
+	assert.Equal(t, int64(1), stats.Timeouts)
+	assert.Equal(t, int64(2), stats.Routes["/fast"].Count)
+	assert.Equal(t, int64(0), stats.Routes["/fast"].Timeouts)
+	assert.Equal(t, int64(1), stats.Routes["/slow"].Timeouts)
+	assert.GreaterOrEqual(t, stats.Routes["/slow"].P95, 50*time.Millisecond)
+
+	assert.Equal(t, Stats{}, NewTimeout().Stats())
+}
@@ -1,93 +1,704 @@
 package timeout
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-var bufPool *BufferPool
-
 const (
 	defaultTimeout = 5 * time.Second
 )
 
 // New wraps a handler and aborts the process of the handler if the timeout is reached
 func New(opts ...Option) gin.HandlerFunc {
+	return newTimeout(opts...).Middleware()
+}
+
+// NewTimeout returns a Timeout whose settings can be changed at runtime,
+// use its Middleware method to get the gin handler
+func NewTimeout(opts ...Option) *Timeout {
+	return newTimeout(opts...)
+}
+
+// NewE is like New but validates the configuration and returns an error
+// instead of panicking or silently accepting nonsensical values
+func NewE(opts ...Option) (gin.HandlerFunc, error) {
+	t, err := buildTimeout(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return t.Middleware(), nil
+}
+
+// Middleware returns the gin handler enforcing the timeout
+func (t *Timeout) Middleware() gin.HandlerFunc {
+	var sem chan struct{}
+	if t.maxConcurrent > 0 {
+		sem = make(chan struct{}, t.maxConcurrent)
+	}
+
+	if t.expvarPrefix != "" {
+		t.publishExpvar(t.expvarPrefix)
+	}
+
+	if t.policySource != nil {
+		t.policyOnce.Do(func() { t.policyCache = newPolicyCache(t.policySource) })
+	}
+
+	return func(c *gin.Context) {
+		arrival := t.clock.Now()
+		if t.startTime != nil {
+			arrival = t.startTime(c)
+		}
+
+		if t.skipPath(c.Request.URL.Path) || t.fastPath(c) {
+			t.handler(c)
+			return
+		}
+
+		t.setRequestID(c)
+		t.countRequest()
+		timeout := t.nestedBound(c, t.requestTimeout(c))
+		if timeout <= 0 {
+			t.handler(c)
+			return
+		}
+
+		var lead *flight
+		// a range request asks for a different response than the full one
+		if t.flights != nil && idempotent(c) && c.GetHeader("Range") == "" {
+			key := t.flights.key(c)
+			f, leader := t.flights.join(key)
+			if !leader {
+				var served bool
+				if served, timeout = t.awaitFlight(c, f, timeout, arrival); served {
+					return
+				}
+			} else {
+				lead = f
+				defer t.flights.land(key, f)
+			}
+		}
+
+		if t.admission != nil && !t.admission(c, t.load()) {
+			t.reject(c)
+			return
+		}
+
+		if sem != nil && !t.acquire(c, sem) {
+			t.reject(c)
+			return
+		}
+
+		if !t.track() {
+			if sem != nil {
+				<-sem
+			}
+			t.reject(c)
+			return
+		}
+
+		var clientGone <-chan struct{}
+		if t.clientGone != nil {
+			clientGone = c.Request.Context().Done()
+		}
+
+		// with WithStartAfterBody or a read budget the deadline of the handler
+		// only starts once the body was read
+		var bodyRead <-chan struct{}
+		if t.startAfterBody || t.readTimeout > 0 {
+			bodyRead = watchBody(c.Request)
+		}
+
+		w := c.Writer
+		expiry := newDeadline(c, t.clock)
+		if bodyRead == nil {
+			expiry.set(t.clock.Now().Add(timeout))
+		}
+		newTracker(c)
+		c.Set(originalKey, c)
+		if t.sharedKeys {
+			Shared(c)
+		}
+		defer t.startTrace(c)()
+		t.hooks.start(c)
+		results := make(chan *attempt, 2+2*t.retries)
+		enforced := !t.dryRun && sampled(t.enforceRatio)
+		// shadowed is set once the budget ran out without being enforced
+		var shadowed bool
+		// the context of the handler expires with its budget when that is
+		// fixed up front and enforced, otherwise it is only canceled on timeout
+		var ctxDeadline time.Time
+		if enforced && bodyRead == nil && !t.idleTimeout && t.async == nil {
+			ctxDeadline = time.Now().Add(timeout)
+		}
+		a := t.startAttempt(c, w, ctxDeadline, results, func() {
+			t.untrack()
+			if sem != nil {
+				<-sem
+			}
+		})
+		// hedge is the second attempt racing a, if any
+		var hedge *attempt
+		start := a.start
+		retries := t.retries
+		phase, budget := PhaseProcess, timeout
+		// one timer is moved for every budget of the request
+		timer := t.clock.NewTimer(timeout)
+		defer timer.Stop()
+		var deadline <-chan time.Time
+		switch {
+		case bodyRead == nil:
+			deadline = timer.C()
+		case t.readTimeout > 0:
+			phase, budget = PhaseRead, t.readTimeout
+			timer.Reset(t.readTimeout)
+			deadline = timer.C()
+		default:
+			timer.Stop()
+		}
+		hedgeAt := t.hedgeTimer(c, timeout)
+		flushAt := t.flushTimer()
+
+		for {
+			select {
+			case r := <-results:
+				if r.detached {
+					continue
+				}
+				if hedge != nil {
+					// the first attempt to return wins, drop the other one
+					if r == hedge {
+						t.detach(a)
+						t.reportCompletion(a)
+					} else {
+						t.detach(hedge)
+						t.reportCompletion(hedge)
+					}
+				}
+				if r.panic != nil {
+					p := r.panic
+					t.setDurations(c, arrival, start, shadowed)
+					t.countPanic(c)
+					t.hooks.panic(c, p.value, p.stack)
+					if t.errorReporter != nil {
+						t.errorReporter(c, panicError(p.value), p.stack)
+					}
+					r.tw.FreeBuffer()
+					if h := t.mappedPanicResponse(p.value); h != nil {
+						c.Abort()
+						h(c)
+						return
+					}
+					if t.panicHandler != nil {
+						c.Abort()
+						t.panicHandler(c, p.value, p.stack)
+						return
+					}
+					panic(p.value)
+				}
+
+				mergeContext(c, r)
+				// the rest of the chain already ran on the copy of the context
+				c.Abort()
+				t.setDurations(c, arrival, start, shadowed)
+				c.Writer = r.tw
+				t.stampTiming(r.tw.Header(), Elapsed(c), timeout)
+				if err := t.intercept(c, r.tw); err != nil {
+					// never send a response the interceptor refused
+					_ = c.Error(err)
+					r.tw.FreeBuffer()
+					t.bufPool.Put(r.buffer)
+					c.Writer = w
+					c.AbortWithStatus(http.StatusInternalServerError)
+					t.hooks.finish(c)
+					return
+				}
+				t.setETag(c, r.tw)
+				if t.cache != nil || lead != nil {
+					resp, ok := successful(r.tw)
+					if ok && t.cache != nil {
+						t.cache.store(c, resp, t.clock.Now())
+					}
+					if lead != nil {
+						lead.resp, lead.ok = resp, ok
+					}
+				}
+				var err error
+				t.region(c, "flush", func() { err = t.flushResponse(c, w, r.tw) })
+				if err != nil {
+					if !errors.Is(err, os.ErrDeadlineExceeded) {
+						panic(err)
+					}
+					// the client was too slow to read the response, give up on it
+					c.Set(infoKey, t.timeoutInfo(c, PhaseWrite, t.flushDeadline))
+					_ = c.Error(err)
+				}
+				r.tw.FreeBuffer()
+				t.bufPool.Put(r.buffer)
+				t.reportSlow(c)
+				t.hooks.finish(c)
+				return
+
+			case <-bodyRead:
+				bodyRead = nil
+				phase, budget = PhaseProcess, timeout
+				expiry.set(t.clock.Now().Add(timeout))
+				timer.Reset(timeout)
+				deadline = timer.C()
+
+			case <-a.activity:
+				// in idle mode each write of the handler restarts its budget
+				if phase == PhaseProcess && deadline != nil {
+					expiry.set(t.clock.Now().Add(timeout))
+					timer.Reset(timeout)
+				}
+
+			case <-flushAt:
+				// while a hedge races it is not known yet whose output to send
+				if tw, ok := a.tw.(*Writer); ok && hedge == nil {
+					tw.flushPending()
+				}
+				flushAt = t.flushTimer()
+
+			case <-hedgeAt:
+				if !a.tw.Committed() && t.track() {
+					hedge = t.startAttempt(c, w, ctxDeadline, results, t.untrack)
+				}
+
+			case <-deadline:
+				if !enforced {
+					// shadow mode, record the timeout and let the handler answer
+					c.Set(shadowKey, true)
+					shadowed = true
+					t.countTimeout(c)
+					t.hooks.timeout(c)
+					deadline = nil
+					continue
+				}
+				if hedge != nil {
+					t.detach(hedge)
+					t.reportCompletion(hedge)
+					hedge = nil
+				}
+				if phase == PhaseProcess && retries > 0 && !a.tw.Committed() && t.shouldRetry(c) && t.track() {
+					// give up on this attempt and run the handler again with a fresh budget
+					retries--
+					t.detach(a)
+					t.reportCompletion(a)
+					if !ctxDeadline.IsZero() {
+						ctxDeadline = time.Now().Add(timeout)
+					}
+					a = t.startAttempt(c, w, ctxDeadline, results, t.untrack)
+					timer.Reset(timeout)
+					hedgeAt = t.hedgeTimer(c, timeout)
+					continue
+				}
+
+				c.Abort()
+				c.Set(timedOutKey, true)
+				t.setDurations(c, arrival, start, true)
+				t.countTimeout(c)
+				info := t.timeoutInfo(c, phase, budget)
+				if a.label != "" {
+					info.Stack = handlerStack(a.label)
+				}
+				c.Set(infoKey, info)
+				t.observeStages(c, info.Stages)
+				t.recordTimeout(c, info)
+				_ = c.Error(ErrTimeout)
+				if t.async != nil && t.runAsync(c, a) {
+					t.hooks.timeout(c)
+					return
+				}
+				t.detach(a)
+
+				if !a.tw.Committed() {
+					t.preserveHeaders(w.Header(), timedOutHeader(a.tw))
+					t.stampTiming(w.Header(), Elapsed(c), budget)
+					switch {
+					case phase == PhaseRead:
+						// the client was too slow, whatever the timeout response is
+						defaultResponse(c)
+					case t.serveStale(c):
+					case t.fallback != nil:
+						t.fallback(c, a.partial)
+					default:
+						t.Response()(c)
+					}
+				}
+				t.hooks.timeout(c)
+				if t.errorReporter != nil {
+					t.errorReporter(c, ErrTimeout, nil)
+				}
+
+				if t.dump != nil && sampled(t.dumpRate) {
+					t.dump(c, goroutineDump())
+				}
+
+				t.reportCompletion(a)
+				return
+
+			case <-clientGone:
+				c.Abort()
+				t.setDurations(c, arrival, start, shadowed)
+				c.Set(goneKey, true)
+				t.countClientGone(c)
+				_ = c.Error(ErrClientGone)
+				t.detach(a)
+				if hedge != nil {
+					t.detach(hedge)
+				}
+
+				t.clientGone(c)
+
+				t.reportCompletion(a)
+				return
+			}
+		}
+	}
+}
+
+// detach cuts an abandoned attempt off from the client and releases its buffer
+func (t *Timeout) detach(a *attempt) {
+	a.detached = true
+	a.cancel()
+	t.abandon(&a.state)
+	if w, ok := a.tw.(*Writer); ok && t.onLateWrite != nil {
+		// late writes come from the handler, it is safe to copy its context there
+		w.setLateWriteHook(func(n int) { t.onLateWrite(a.c.Copy(), n) })
+	}
+	a.tw.MarkTimeout()
+	if w, ok := a.tw.(*Writer); ok && t.fallback != nil {
+		a.partial = w.partial()
+	}
+	a.tw.FreeBuffer()
+	t.bufPool.Put(a.buffer)
+}
+
+// reportSlow calls the slow request hook when a request finished in time but
+// took longer than the slow threshold
+func (t *Timeout) reportSlow(c *gin.Context) {
+	if t.slow == nil || WouldHaveTimedOut(c) {
+		return
+	}
+	if elapsed := Elapsed(c); elapsed >= t.slowThreshold {
+		t.slow(c, elapsed)
+	}
+}
+
+// reportCompletion calls the completion hooks once the abandoned attempt returns
+func (t *Timeout) reportCompletion(a *attempt) {
+	if t.completion == nil && t.notifier == nil {
+		return
+	}
+	go func() {
+		<-a.done
+		t.completed(a.c.Copy(), completionInfo(a.tw, t.clock.Now().Sub(a.start), a.handlerPanic))
+	}()
+}
+
+// completed calls the completion hooks with the outcome of an abandoned handler
+func (t *Timeout) completed(c *gin.Context, info CompletionInfo) {
+	if t.completion != nil {
+		t.completion(c, info)
+	}
+	if t.notifier != nil {
+		t.notifier(info)
+	}
+}
+
+// defaultPassthrough are the content types streamed unless
+// WithPassthroughContentTypes is set
+var defaultPassthrough = []string{"text/event-stream"}
+
+// newTimeout builds a Timeout from the defaults and the given options
+func newTimeout(opts ...Option) *Timeout {
+	t, err := buildTimeout(opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return t
+}
+
+// buildTimeout builds a Timeout from the defaults and the given options,
+// failing on nil options
+func buildTimeout(opts ...Option) (*Timeout, error) {
 	t := &Timeout{
-		timeout:  defaultTimeout,
-		handler:  nil,
-		response: defaultResponse,
+		handler:        nil,
+		passthrough:    defaultPassthrough,
+		rejectResponse: defaultRejectResponse,
+		clock:          realClock{},
+		enforceRatio:   1,
 	}
+	t.writerFactory = t.newWriter
+
+	defaults.RLock()
+	t.SetTimeout(defaults.timeout)
+	t.SetResponse(defaults.response)
+	t.bufferSize = defaults.bufferSize
+	defaults.RUnlock()
 
 	// Loop through each option
 	for _, opt := range opts {
 		if opt == nil {
-			panic("timeout Option not be nil")
+			return nil, errNilOption
 		}
 
 		// Call the option giving the instantiated
 		opt(t)
 	}
 
-	if t.timeout <= 0 {
-		return t.handler
+	if t.optionErr != nil {
+		return nil, t.optionErr
 	}
+	if t.profiler != nil {
+		t.profiler.clock = t.clock
+	}
+	return t, nil
+}
 
-	bufPool = &BufferPool{}
+// validate checks the configuration for missing handlers,
+// non-positive durations and conflicting settings
+func (t *Timeout) validate() error {
+	switch {
+	case t.handler == nil:
+		return errors.New("timeout: handler must not be nil")
+	case t.Response() == nil:
+		return errors.New("timeout: response handler must not be nil")
+	case t.rejectResponse == nil:
+		return errors.New("timeout: reject response handler must not be nil")
+	case t.writerFactory == nil:
+		return errors.New("timeout: writer factory must not be nil")
+	case t.clock == nil:
+		return errors.New("timeout: clock must not be nil")
+	case t.Timeout() <= 0:
+		return fmt.Errorf("timeout: timeout must be positive, got %s", t.Timeout())
+	case t.minTimeout < 0:
+		return fmt.Errorf("timeout: min timeout must not be negative, got %s", t.minTimeout)
+	case t.maxTimeout < 0:
+		return fmt.Errorf("timeout: max timeout must not be negative, got %s", t.maxTimeout)
+	case t.minTimeout > 0 && t.maxTimeout > 0 && t.minTimeout > t.maxTimeout:
+		return fmt.Errorf("timeout: min timeout %s exceeds max timeout %s", t.minTimeout, t.maxTimeout)
+	case t.maxConcurrent < 0:
+		return fmt.Errorf("timeout: max concurrent must not be negative, got %d", t.maxConcurrent)
+	case t.maxConcurrentWait < 0:
+		return fmt.Errorf("timeout: max concurrent wait must not be negative, got %s", t.maxConcurrentWait)
+	case t.maxConcurrentWait > 0 && t.maxConcurrent == 0:
+		return errors.New("timeout: max concurrent wait requires max concurrent")
+	case t.status != 0 && (t.status < 100 || t.status > 999):
+		return fmt.Errorf("timeout: invalid status %d", t.status)
+	case t.enforceRatio < 0 || t.enforceRatio > 1:
+		return fmt.Errorf("timeout: enforcement ratio must be within [0, 1], got %v", t.enforceRatio)
+	case t.hedgeAfter < 0 || t.hedgeAfter >= 1:
+		return fmt.Errorf("timeout: hedge fraction must be within [0, 1), got %v", t.hedgeAfter)
+	case t.retries < 0:
+		return fmt.Errorf("timeout: retries must not be negative, got %d", t.retries)
+	case t.flushInterval < 0:
+		return fmt.Errorf("timeout: flush interval must not be negative, got %s", t.flushInterval)
+	case t.hedgeAfter > 0 && (t.writeThrough || t.streamAfter > 0 || t.streamReaders):
+		// both executions could stream to the client at the same time
+		return errors.New("timeout: hedging cannot be combined with a streaming writer mode")
+	case t.idleTimeout && t.hedgeAfter > 0:
+		// the hedge would fire on a handler whose idle budget keeps restarting
+		return errors.New("timeout: idle timeout cannot be combined with hedging")
+	}
+	return nil
+}
 
-	return func(c *gin.Context) {
-		finish := make(chan struct{}, 1)
-		panicChan := make(chan interface{}, 1)
+// intercept passes the buffered response to the flush interceptor, if any
+func (t *Timeout) intercept(c *gin.Context, tw BufferedWriter) error {
+	w, ok := tw.(*Writer)
+	if t.interceptor == nil || !ok || w.Committed() {
+		return nil
+	}
+	status := w.Status()
+	_, err := w.rewriteBody(func(body *bytes.Buffer) error {
+		return t.interceptor(c, status, w.headers, body)
+	})
+	return err
+}
 
-		w := c.Writer
-		buffer := bufPool.Get()
-		tw := NewWriter(w, buffer)
-		c.Writer = tw
-		buffer.Reset()
+// flushResponse sends the buffered response, compressed if enabled, or a 304
+// without body in its place when the client already has it, see WithNotModified
+func (t *Timeout) flushResponse(c *gin.Context, w gin.ResponseWriter, tw BufferedWriter) error {
+	if !tw.Committed() {
+		t.stripHeaders(tw.Header())
+	}
+	if !t.notModified(c, tw) {
+		if err := t.compress(c, tw); err != nil {
+			return err
+		}
+		if err := t.setDigest(tw); err != nil {
+			return err
+		}
+		return t.flush(w, tw)
+	}
+	for _, name := range notModifiedHeaders {
+		if v := tw.Header().Values(name); len(v) > 0 {
+			w.Header()[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	w.WriteHeaderNow()
+	// later middlewares see what was actually sent
+	c.Writer = w
+	return nil
+}
 
-		go func() {
-			defer func() {
-				if p := recover(); p != nil {
-					panicChan <- p
-				}
-			}()
-			t.handler(c)
-			finish <- struct{}{}
-		}()
-
-		select {
-		case p := <-panicChan:
-			tw.FreeBuffer()
-			c.Writer = w
-			panic(p)
-
-		case <-finish:
-			c.Next()
-			tw.mu.Lock()
-			defer tw.mu.Unlock()
-			dst := tw.ResponseWriter.Header()
-			for k, vv := range tw.Header() {
-				dst[k] = vv
-			}
+// flushTimer fires when the output buffered so far should be sent, it is nil
+// unless WithFlushInterval is set
+func (t *Timeout) flushTimer() <-chan time.Time {
+	if t.flushInterval <= 0 {
+		return nil
+	}
+	return t.clock.After(t.flushInterval)
+}
 
-			if _, err := tw.ResponseWriter.Write(buffer.Bytes()); err != nil {
-				panic(err)
-			}
-			tw.FreeBuffer()
-			bufPool.Put(buffer)
+// flush writes the buffered response, bounded by the flush deadline if any
+func (t *Timeout) flush(w gin.ResponseWriter, tw BufferedWriter) error {
+	if t.flushDeadline <= 0 {
+		return tw.FlushBuffer()
+	}
+
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(t.flushDeadline)); err != nil {
+		// the underlying writer does not support deadlines, flush unbounded
+		return tw.FlushBuffer()
+	}
+	defer func() { _ = rc.SetWriteDeadline(time.Time{}) }()
+
+	if err := tw.FlushBuffer(); err != nil {
+		return err
+	}
+	// push the data out while the deadline still applies
+	return rc.Flush()
+}
 
-		case <-time.After(t.timeout):
-			c.Abort()
-			tw.mu.Lock()
-			defer tw.mu.Unlock()
-			tw.timeout = true
-			tw.FreeBuffer()
-			bufPool.Put(buffer)
+// timedOutHeader returns the headers buffered by the handler of a detached
+// attempt, which may still be running
+func timedOutHeader(tw BufferedWriter) http.Header {
+	if w, ok := tw.(*Writer); ok {
+		return w.timedOutHeader()
+	}
+	return tw.Header()
+}
 
-			c.Writer = w
-			t.response(c)
-			c.Writer = tw
+// preserveHeaders copies the allowlisted headers buffered by the handler
+// onto the timeout response
+func (t *Timeout) preserveHeaders(dst, buffered http.Header) {
+	for _, k := range t.preservedHeaders {
+		if vv := buffered.Values(k); len(vv) > 0 {
+			dst[k] = append([]string(nil), vv...)
 		}
 	}
 }
+
+// newWriter is the default WriterFactory
+func (t *Timeout) newWriter(w gin.ResponseWriter, buf *bytes.Buffer) BufferedWriter {
+	tw := NewWriter(w, buf)
+	if t.hedgeAfter == 0 {
+		// a hedged execution must not stream next to the first one
+		tw.passthrough = t.passthrough
+	}
+	tw.streamAfter = t.streamAfter
+	tw.writeThrough = t.writeThrough
+	tw.spillThreshold = t.spillThreshold
+	tw.spillDir = t.spillDir
+	tw.closedErr = t.writerClosedErr
+	tw.streamReaders = t.streamReaders
+	tw.keepHeaders = len(t.preservedHeaders) > 0 || t.fallback != nil
+	return tw
+}
+
+// Timeout returns the current default timeout
+func (t *Timeout) Timeout() time.Duration {
+	return time.Duration(t.timeout.Load())
+}
+
+// SetTimeout changes the default timeout, it is safe to call while serving requests
+func (t *Timeout) SetTimeout(timeout time.Duration) {
+	t.timeout.Store(int64(timeout))
+}
+
+// Response returns the current timeout response handler
+func (t *Timeout) Response() gin.HandlerFunc {
+	if h := t.response.Load(); h != nil {
+		return *h
+	}
+	return nil
+}
+
+// SetResponse changes the timeout response handler, it is safe to call while serving requests
+func (t *Timeout) SetResponse(h gin.HandlerFunc) {
+	t.response.Store(&h)
+}
+
+// setDurations records the handler and end-to-end durations on the context,
+// timedOut is whether the budget ran out, enforced or not
+func (t *Timeout) setDurations(c *gin.Context, arrival, start time.Time, timedOut bool) {
+	now := t.clock.Now()
+	c.Set(elapsedKey, now.Sub(start))
+	c.Set(latencyKey, now.Sub(arrival))
+	t.observeDuration(c, now.Sub(start), now.Sub(arrival), timedOut)
+}
+
+// setRequestID stores the correlation ID of the request on the context
+func (t *Timeout) setRequestID(c *gin.Context) {
+	if t.requestIDKey == "" {
+		return
+	}
+	id := c.GetString(t.requestIDKey)
+	if id == "" {
+		id = c.GetHeader(t.requestIDKey)
+	}
+	if id == "" {
+		id = c.Writer.Header().Get(t.requestIDKey)
+	}
+	if id != "" {
+		c.Set(idKey, id)
+	}
+}
+
+// load returns a snapshot of the current load
+func (t *Timeout) load() Load {
+	return Load{
+		InFlight:  t.inFlight.Load(),
+		Waiting:   t.waiting.Load(),
+		Abandoned: t.abandoned.Load(),
+	}
+}
+
+// acquire takes a slot from sem, waiting at most maxConcurrentWait for one to free up
+func (t *Timeout) acquire(c *gin.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+
+	if t.maxConcurrentWait <= 0 {
+		return false
+	}
+
+	t.waiting.Add(1)
+	defer t.waiting.Add(-1)
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.clock.After(t.maxConcurrentWait):
+		return false
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
@@ -1,9 +1,16 @@
 package timeout
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -100,3 +107,547 @@ func TestPanic(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Equal(t, "", w.Body.String())
 }
+
+type recordingWriter struct {
+	*Writer
+	flushed bool
+}
+
+func (w *recordingWriter) FlushBuffer() error {
+	w.flushed = true
+	return w.Writer.FlushBuffer()
+}
+
+func TestWriterFactory(t *testing.T) {
+	var rw *recordingWriter
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithHandler(func(c *gin.Context) {
+			c.String(http.StatusOK, "custom")
+		}),
+		WithWriterFactory(func(w gin.ResponseWriter, buf *bytes.Buffer) BufferedWriter {
+			rw = &recordingWriter{Writer: NewWriter(w, buf)}
+			return rw
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "custom", w.Body.String())
+	assert.True(t, rw.flushed)
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithMaxConcurrent(1),
+		WithHandler(func(c *gin.Context) {
+			close(started)
+			<-release
+			c.String(http.StatusOK, "")
+		}),
+	))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+		r.ServeHTTP(w, req)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusServiceUnavailable), w.Body.String())
+
+	close(release)
+	<-done
+}
+
+func TestAdmission(t *testing.T) {
+	var load Load
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithHandler(emptySuccessResponse2),
+		WithAdmission(func(c *gin.Context, l Load) bool {
+			load = l
+			return c.Query("shed") == ""
+		}),
+		WithRejectResponse(func(c *gin.Context) {
+			c.String(http.StatusTooManyRequests, "busy")
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/?shed=1", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "busy", w.Body.String())
+	assert.Equal(t, Load{}, load)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestTimeoutError(t *testing.T) {
+	var errs []*gin.Error
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.GET("/", New(WithTimeout(50*time.Microsecond), WithHandler(emptySuccessResponse)))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrTimeout)
+	assert.ErrorIs(t, errs[0], context.DeadlineExceeded)
+}
+
+func TestNewE(t *testing.T) {
+	h, err := NewE(WithTimeout(1*time.Second), WithHandler(emptySuccessResponse2))
+	assert.NoError(t, err)
+	assert.NotNil(t, h)
+
+	cases := map[string][]Option{
+		"nil option":        {nil},
+		"nil handler":       {WithTimeout(1 * time.Second)},
+		"nil response":      {WithHandler(emptySuccessResponse), WithResponse(nil)},
+		"negative timeout":  {WithHandler(emptySuccessResponse), WithTimeout(-1)},
+		"min exceeds max":   {WithHandler(emptySuccessResponse), WithMinTimeout(2), WithMaxTimeout(1)},
+		"wait without max":  {WithHandler(emptySuccessResponse), WithMaxConcurrentWait(1)},
+		"negative parallel": {WithHandler(emptySuccessResponse), WithMaxConcurrent(-1)},
+		"invalid status":    {WithHandler(emptySuccessResponse), WithStatus(42)},
+		"ratio above one":   {WithHandler(emptySuccessResponse), WithEnforcementRatio(1.5)},
+		"negative ratio":    {WithHandler(emptySuccessResponse), WithEnforcementRatio(-0.1)},
+		"hedge at budget":   {WithHandler(emptySuccessResponse), WithHedge(1)},
+		"negative hedge":    {WithHandler(emptySuccessResponse), WithHedge(-0.5)},
+		"negative retries":  {WithHandler(emptySuccessResponse), WithRetry(-1, nil)},
+		"negative flush":    {WithHandler(emptySuccessResponse), WithFlushInterval(-time.Second)},
+		"idle with hedge":   {WithHandler(emptySuccessResponse), WithIdleTimeout(), WithHedge(0.5)},
+		"hedge and stream":  {WithHandler(emptySuccessResponse), WithHedge(0.5), WithWriteThrough()},
+		"hedge and readers": {WithHandler(emptySuccessResponse), WithHedge(0.5), WithReaderStreaming()},
+		"hedge after bytes": {WithHandler(emptySuccessResponse), WithHedge(0.5), WithStreamAfter(1)},
+	}
+	for name, opts := range cases {
+		h, err := NewE(opts...)
+		assert.Error(t, err, name)
+		assert.Nil(t, h, name)
+	}
+
+	assert.PanicsWithValue(t, "timeout Option not be nil", func() {
+		New(nil)
+	})
+}
+
+func TestRuntimeReconfigure(t *testing.T) {
+	tm := NewTimeout(
+		WithTimeout(1*time.Second),
+		WithHandler(emptySuccessResponse),
+	)
+	r := gin.New()
+	r.GET("/", tm.Middleware())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	tm.SetTimeout(50 * time.Microsecond)
+	tm.SetResponse(testResponse)
+	assert.Equal(t, 50*time.Microsecond, tm.Timeout())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, "test response", w.Body.String())
+}
+
+func TestFlushDeadline(t *testing.T) {
+	payload := strings.Repeat("x", 64<<20)
+	errCh := make(chan error, 1)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		errCh <- c.Errors.Last()
+	})
+	r.GET("/", New(
+		WithTimeout(5*time.Second),
+		WithFlushDeadline(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			c.String(http.StatusOK, payload)
+		}),
+	))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	// a client that sends a request but never reads the response
+	var d net.Dialer
+	conn, err := d.DialContext(context.Background(), "tcp", srv.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n"))
+	assert.NoError(t, err)
+
+	select {
+	case err := <-errCh:
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("flush was not bounded by the deadline")
+	}
+}
+
+func TestPreservedHeaders(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithPreservedHeaders("access-control-allow-origin", "X-Request-Id"),
+		WithHandler(func(c *gin.Context) {
+			c.Header("Access-Control-Allow-Origin", "*")
+			c.Header("X-Request-Id", "abc")
+			c.Header("X-Internal", "secret")
+			time.Sleep(100 * time.Millisecond)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "abc", w.Header().Get("X-Request-Id"))
+	assert.Empty(t, w.Header().Get("X-Internal"))
+}
+
+func TestWithStatus(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(time.Millisecond)
+		}),
+		WithStatus(http.StatusGatewayTimeout),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusGatewayTimeout), w.Body.String())
+}
+
+func TestWithClientGone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	var status int
+	var gone bool
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		status = c.Writer.Status()
+		gone = IsClientGone(c)
+	})
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			cancel()
+			<-release
+		}),
+		WithClientGone(ClientGoneResponse),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, StatusClientClosedRequest, status)
+	assert.True(t, gone)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestWithFallback(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			c.Header("X-Items", "1")
+			_, _ = c.Writer.WriteString("item1,")
+			time.Sleep(100 * time.Millisecond)
+		}),
+		WithFallback(func(c *gin.Context, partial Partial) {
+			c.Header("X-Partial", "true")
+			c.Data(http.StatusOK, "text/plain", append(partial.Body, "..."...))
+			assert.Equal(t, "1", partial.Header.Get("X-Items"))
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Partial"))
+	assert.Equal(t, "item1,...", w.Body.String())
+}
+
+func TestWithDryRun(t *testing.T) {
+	var shadow bool
+	var timeouts int
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		shadow = WouldHaveTimedOut(c)
+	})
+	r.GET("/", New(
+		WithTimeout(10*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(30 * time.Millisecond)
+			c.String(http.StatusOK, "slow but fine")
+		}),
+		WithHooks(Hooks{OnTimeout: func(c *gin.Context) { timeouts++ }}),
+		WithDryRun(true),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "slow but fine", w.Body.String())
+	assert.True(t, shadow)
+	assert.Equal(t, 1, timeouts)
+}
+
+func TestWithEnforcementRatio(t *testing.T) {
+	for ratio, code := range map[float64]int{0: http.StatusOK, 1: http.StatusRequestTimeout} {
+		r := gin.New()
+		r.GET("/", New(
+			WithTimeout(10*time.Millisecond),
+			WithHandler(func(c *gin.Context) {
+				time.Sleep(30 * time.Millisecond)
+				c.Status(http.StatusOK)
+			}),
+			WithEnforcementRatio(ratio),
+		))
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code)
+	}
+}
+
+func TestWithStartAfterBody(t *testing.T) {
+	r := gin.New()
+	r.POST("/", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			body, _ := io.ReadAll(c.Request.Body)
+			c.String(http.StatusOK, string(body))
+		}),
+		WithStartAfterBody(),
+	))
+
+	pr, pw := io.Pipe()
+	go func() {
+		for _, part := range []string{"slow", " ", "upload"} {
+			time.Sleep(15 * time.Millisecond)
+			_, _ = pw.Write([]byte(part))
+		}
+		_ = pw.Close()
+	}()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", pr)
+	req.ContentLength = -1
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "slow upload", w.Body.String())
+}
+
+func TestWithPhaseBudgets(t *testing.T) {
+	var phase Phase
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		info, _ := TimeoutInfo(c)
+		phase = info.Phase
+	})
+	r.POST("/", New(
+		WithHandler(func(c *gin.Context) {
+			_, _ = io.ReadAll(c.Request.Body)
+			c.Status(http.StatusOK)
+		}),
+		WithPhaseBudgets(20*time.Millisecond, time.Second, 0),
+		WithStatus(http.StatusGatewayTimeout),
+	))
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", pr)
+	req.ContentLength = -1
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, PhaseRead, phase)
+}
+
+func TestWithSlowThreshold(t *testing.T) {
+	var slow []time.Duration
+	r := gin.New()
+	r.GET("/:delay", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			d, _ := time.ParseDuration(c.Param("delay"))
+			time.Sleep(d)
+			c.Status(http.StatusOK)
+		}),
+		WithSlowThreshold(20*time.Millisecond, func(c *gin.Context, elapsed time.Duration) {
+			slow = append(slow, elapsed)
+		}),
+	))
+
+	for _, delay := range []string{"1ms", "30ms"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/"+delay, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	if assert.Len(t, slow, 1) {
+		assert.GreaterOrEqual(t, slow[0], 30*time.Millisecond)
+	}
+}
+
+func TestWithFlushInterceptor(t *testing.T) {
+	r := gin.New()
+	r.Use(New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) { c.Next() }),
+		WithFlushInterceptor(func(c *gin.Context, status int, header http.Header, body *bytes.Buffer) error {
+			if c.FullPath() == "/refused" {
+				return errors.New("refused")
+			}
+			redacted := bytes.ReplaceAll(body.Bytes(), []byte("secret"), []byte("******"))
+			body.Reset()
+			body.Write(redacted)
+			header.Set("X-Status", strconv.Itoa(status))
+			return nil
+		}),
+	))
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusCreated, "the secret is out") })
+	r.GET("/refused", func(c *gin.Context) { c.String(http.StatusOK, "secret") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "the ****** is out", w.Body.String())
+	assert.Equal(t, "201", w.Header().Get("X-Status"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/refused", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestWithStripHeaders(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			c.Header("Connection", "close, X-Hop")
+			c.Header("X-Hop", "1")
+			c.Header("Transfer-Encoding", "chunked")
+			c.Header("Keep-Alive", "timeout=5")
+			c.Header("X-Internal", "1")
+			c.Header("X-Kept", "1")
+			c.String(http.StatusOK, "ok")
+		}),
+		WithStripHeaders("X-Internal"),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, name := range []string{"Connection", "X-Hop", "Transfer-Encoding", "Keep-Alive", "X-Internal"} {
+		assert.Empty(t, w.Header().Values(name), name)
+	}
+	assert.Equal(t, "1", w.Header().Get("X-Kept"))
+}
+
+func TestWithFlushInterval(t *testing.T) {
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(100*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			defer close(done)
+			c.String(http.StatusAccepted, "step 1\n")
+			time.Sleep(150 * time.Millisecond)
+			c.String(http.StatusAccepted, "step 2\n")
+		}),
+		WithFlushInterval(10*time.Millisecond),
+	))
+
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-done
+
+	// step 1 was flushed before the timeout, which only cuts the response short
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "step 1\n", w.Body.String())
+	assert.True(t, w.Flushed)
+}
+
+func TestWithIdleTimeout(t *testing.T) {
+	r := gin.New()
+	r.GET("/:stall", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			stall, _ := time.ParseDuration(c.Param("stall"))
+			for i := 0; i < 5; i++ {
+				time.Sleep(stall)
+				c.String(http.StatusOK, strconv.Itoa(i))
+			}
+		}),
+		WithIdleTimeout(),
+	))
+
+	// a handler that keeps writing may run longer than the timeout
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/20ms", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "01234", w.Body.String())
+
+	// one that stalls is cut
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/80ms", nil))
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+}
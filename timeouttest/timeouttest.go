@@ -0,0 +1,118 @@
+// Package timeouttest provides helpers for testing handlers served behind the
+// timeout middleware.
+package timeouttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/timeout"
+	"github.com/gin-gonic/gin"
+)
+
+// Result records which response won the race between handler and timeout
+type Result struct {
+	// Recorder holds the response sent to the client
+	Recorder *httptest.ResponseRecorder
+	// TimedOut is true if the timeout response was sent
+	TimedOut bool
+	// Elapsed is how long the handler ran as measured by the middleware
+	Elapsed time.Duration
+}
+
+// Serve sends a GET request to handler wrapped by the middleware built from opts
+func Serve(handler gin.HandlerFunc, opts ...timeout.Option) Result {
+	return ServeRequest(httptest.NewRequest(http.MethodGet, "/", nil), handler, opts...)
+}
+
+// ServeRequest sends req to handler wrapped by the middleware built from opts
+func ServeRequest(req *http.Request, handler gin.HandlerFunc, opts ...timeout.Option) Result {
+	var res Result
+	r := gin.New()
+	r.Any("/*path",
+		func(c *gin.Context) {
+			c.Next()
+			res.TimedOut = timeout.IsTimedOut(c)
+			res.Elapsed = timeout.Elapsed(c)
+		},
+		timeout.New(append(opts[:len(opts):len(opts)], timeout.WithHandler(handler))...),
+	)
+
+	res.Recorder = httptest.NewRecorder()
+	r.ServeHTTP(res.Recorder, req)
+	return res
+}
+
+// AssertTimesOut fails t unless handler times out with a budget of d
+func AssertTimesOut(t testing.TB, handler gin.HandlerFunc, d time.Duration, opts ...timeout.Option) Result {
+	t.Helper()
+	res := Serve(handler, append([]timeout.Option{timeout.WithTimeout(d)}, opts...)...)
+	if !res.TimedOut {
+		t.Errorf("handler did not time out within %s, got status %d", d, res.Recorder.Code)
+	}
+	return res
+}
+
+// AssertCompletes fails t if handler times out with a budget of d
+func AssertCompletes(t testing.TB, handler gin.HandlerFunc, d time.Duration, opts ...timeout.Option) Result {
+	t.Helper()
+	res := Serve(handler, append([]timeout.Option{timeout.WithTimeout(d)}, opts...)...)
+	if res.TimedOut {
+		t.Errorf("handler timed out after %s", d)
+	}
+	return res
+}
+
+// SlowHandler is a handler that blocks until released, then responds
+type SlowHandler struct {
+	Status int
+	Body   string
+
+	once     sync.Once
+	started  chan struct{}
+	release  chan struct{}
+	finished chan struct{}
+}
+
+// NewSlowHandler will return a SlowHandler responding with status and body once released
+func NewSlowHandler(status int, body string) *SlowHandler {
+	return &SlowHandler{
+		Status:   status,
+		Body:     body,
+		started:  make(chan struct{}, 1),
+		release:  make(chan struct{}),
+		finished: make(chan struct{}, 1),
+	}
+}
+
+// Handle is the gin handler, it can serve several requests
+func (h *SlowHandler) Handle(c *gin.Context) {
+	select {
+	case h.started <- struct{}{}:
+	default:
+	}
+	<-h.release
+	c.String(h.Status, h.Body)
+	select {
+	case h.finished <- struct{}{}:
+	default:
+	}
+}
+
+// Release unblocks current and future requests
+func (h *SlowHandler) Release() {
+	h.once.Do(func() { close(h.release) })
+}
+
+// Started fires when a request entered the handler
+func (h *SlowHandler) Started() <-chan struct{} {
+	return h.started
+}
+
+// Finished fires when a request left the handler
+func (h *SlowHandler) Finished() <-chan struct{} {
+	return h.finished
+}
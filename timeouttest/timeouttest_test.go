@@ -0,0 +1,48 @@
+package timeouttest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAssertTimesOut(t *testing.T) {
+	h := NewSlowHandler(http.StatusOK, "late")
+	defer h.Release()
+
+	res := AssertTimesOut(t, h.Handle, time.Millisecond)
+	if res.Recorder.Code != http.StatusRequestTimeout {
+		t.Errorf("status = %d, want %d", res.Recorder.Code, http.StatusRequestTimeout)
+	}
+	<-h.Started()
+}
+
+func TestAssertCompletes(t *testing.T) {
+	h := NewSlowHandler(http.StatusCreated, "done")
+	h.Release()
+
+	res := AssertCompletes(t, h.Handle, time.Second)
+	if res.Recorder.Code != http.StatusCreated || res.Recorder.Body.String() != "done" {
+		t.Errorf("got %d %q", res.Recorder.Code, res.Recorder.Body.String())
+	}
+	<-h.Finished()
+}
+
+func TestAssertTimesOutFails(t *testing.T) {
+	ft := &testing.T{}
+	AssertTimesOut(ft, func(c *gin.Context) {}, time.Second)
+	if !ft.Failed() {
+		t.Error("AssertTimesOut did not fail for a fast handler")
+	}
+}
+
+func TestServeDefaultStatus(t *testing.T) {
+	res := Serve(func(c *gin.Context) {
+		_, _ = c.Writer.WriteString("ok")
+	})
+	if res.Recorder.Code != http.StatusOK || res.TimedOut {
+		t.Errorf("got %d timed out %t", res.Recorder.Code, res.TimedOut)
+	}
+}
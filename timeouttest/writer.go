@@ -0,0 +1,40 @@
+package timeouttest
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http/httptest"
+
+	"github.com/gin-contrib/timeout"
+	"github.com/gin-gonic/gin"
+)
+
+// ResponseRecorder is an httptest.ResponseRecorder that can be hijacked, the
+// connection handed out is one end of an in-memory pipe
+type ResponseRecorder struct {
+	*httptest.ResponseRecorder
+	// Conn is the other end of the hijacked connection, nil until Hijack was called
+	Conn net.Conn
+}
+
+// NewRecorder returns an initialized ResponseRecorder
+func NewRecorder() *ResponseRecorder {
+	return &ResponseRecorder{ResponseRecorder: httptest.NewRecorder()}
+}
+
+// Hijack implements http.Hijacker
+func (r *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	r.Conn = client
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+// NewTestWriter will return a timeout.Writer backed by a ResponseRecorder, so
+// middleware interacting with timeout.Writer can be tested without a gin engine.
+// Call FlushBuffer to move buffered output into the recorder.
+func NewTestWriter() (*timeout.Writer, *ResponseRecorder) {
+	rec := NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	return timeout.NewWriter(c.Writer, &bytes.Buffer{}), rec
+}
@@ -0,0 +1,46 @@
+package timeouttest
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestNewTestWriter(t *testing.T) {
+	w, rec := NewTestWriter()
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.WriteString("buffered"); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q before FlushBuffer, want empty", rec.Body.String())
+	}
+	if err := w.FlushBuffer(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusCreated || rec.Body.String() != "buffered" {
+		t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusCreated, "buffered")
+	}
+}
+
+func TestNewTestWriterHijack(t *testing.T) {
+	w, rec := NewTestWriter()
+	conn, rw, err := w.Hijack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer rec.Conn.Close()
+
+	go func() {
+		_, _ = rw.WriteString("upgraded")
+		_ = rw.Flush()
+	}()
+	got := make([]byte, len("upgraded"))
+	if _, err := io.ReadFull(rec.Conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "upgraded" {
+		t.Errorf("read %q from the hijacked connection, want %q", got, "upgraded")
+	}
+}
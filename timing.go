@@ -0,0 +1,23 @@
+package timeout
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// stampTiming sets the timing headers enabled by WithServerTiming and
+// WithDurationHeader on h
+func (t *Timeout) stampTiming(h http.Header, elapsed, budget time.Duration) {
+	if t.serverTiming {
+		h.Add("Server-Timing", fmt.Sprintf("handler;dur=%s, budget;dur=%s", millis(elapsed), millis(budget)))
+	}
+	if t.durationHeader != "" {
+		h.Set(t.durationHeader, elapsed.String())
+	}
+}
+
+// millis formats d in milliseconds as Server-Timing expects
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
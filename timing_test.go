@@ -0,0 +1,53 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerTiming(t *testing.T) {
+	r := gin.New()
+	r.GET("/:kind", New(
+		WithTimeout(20*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			if c.Param("kind") == "slow" {
+				time.Sleep(50 * time.Millisecond)
+			}
+			c.Status(http.StatusOK)
+		}),
+		WithServerTiming(),
+	))
+
+	for path, code := range map[string]int{"/fast": http.StatusOK, "/slow": http.StatusRequestTimeout} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code)
+		assert.Regexp(t, `^handler;dur=\d+\.\d, budget;dur=20\.0$`, w.Header().Get("Server-Timing"))
+	}
+}
+
+func TestDurationHeader(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(time.Millisecond)
+			c.Status(http.StatusOK)
+		}),
+		WithDurationHeader("X-Response-Time"),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	d, err := time.ParseDuration(w.Header().Get("X-Response-Time"))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, d, time.Millisecond)
+}
@@ -0,0 +1,32 @@
+package timeout
+
+import (
+	"runtime/trace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// traceTaskType is the runtime/trace task type of a request
+const traceTaskType = "gin-timeout"
+
+// startTrace begins the runtime/trace task of the request and sets its context
+// on the request, so regions of the handler are part of the task. The
+// returned func ends the task.
+func (t *Timeout) startTrace(c *gin.Context) func() {
+	if !t.trace {
+		return func() {}
+	}
+	ctx, task := trace.NewTask(c.Request.Context(), traceTaskType)
+	c.Request = c.Request.WithContext(ctx)
+	return task.End
+}
+
+// region runs f in the runtime/trace region name of the request task, f is
+// run directly when tracing is off
+func (t *Timeout) region(c *gin.Context, name string, f func()) {
+	if !t.trace {
+		f()
+		return
+	}
+	trace.WithRegion(c.Request.Context(), name, f)
+}
@@ -0,0 +1,42 @@
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime/trace"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+	if !assert.NoError(t, trace.Start(&out)) {
+		return
+	}
+
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			trace.WithRegion(c.Request.Context(), "custom", func() {
+				c.String(http.StatusOK, "ok")
+			})
+		}),
+		WithTrace(),
+	))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	r.ServeHTTP(w, req)
+	trace.Stop()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	for _, name := range []string{traceTaskType, "buffer", "handler", "flush", "custom"} {
+		assert.Contains(t, out.String(), name)
+	}
+}
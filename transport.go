@@ -0,0 +1,83 @@
+package timeout
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport is an http.RoundTripper bounding outbound requests by the
+// remaining budget of the inbound request. The outbound request context must
+// be the *gin.Context of the inbound request, its request context, or derive
+// from either.
+type Transport struct {
+	// Base performs the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+	// Margin is kept out of the budget, e.g. to serialize the response
+	Margin time.Duration
+	// Header, if set, carries the budget left to the downstream service, see
+	// WithTimeoutHeader
+	Header string
+	// Format encodes the budget in Header, FormatMillis if nil
+	Format func(d time.Duration) string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	deadline, ok := budgetDeadline(req.Context())
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	deadline = deadline.Add(-t.Margin)
+	if d, ok := req.Context().Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	ctx, cancel := context.WithDeadline(req.Context(), deadline)
+	req = req.WithContext(ctx)
+	if t.Header != "" {
+		format := t.Format
+		if format == nil {
+			format = FormatMillis
+		}
+		req.Header = req.Header.Clone()
+		req.Header.Set(t.Header, format(time.Until(deadline)))
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the deadline must outlive RoundTrip until the body is consumed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// budgetDeadline returns the deadline the middleware set for the request
+// ctx belongs to, gin.Context resolves string keys from its Keys while the
+// request context carries the deadline itself
+func budgetDeadline(ctx context.Context) (time.Time, bool) {
+	d, _ := ctx.Value(deadlineKey).(*sharedDeadline)
+	if at := d.wall(); !at.IsZero() {
+		return at, true
+	}
+	return ctx.Deadline()
+}
+
+// cancelBody releases the context of a response once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,78 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &Transport{Margin: 10 * time.Millisecond}}
+	var err error
+	var took time.Duration
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			start := time.Now()
+			req, _ := http.NewRequestWithContext(c, http.MethodGet, upstream.URL, nil)
+			var resp *http.Response
+			resp, err = client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+			took = time.Since(start)
+			c.Status(http.StatusBadGateway)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, took, 50*time.Millisecond)
+}
+
+func TestTransportRequestContext(t *testing.T) {
+	budget := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget <- r.Header.Get("X-Request-Timeout")
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &Transport{Header: "X-Request-Timeout"}}
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			// the request context carries the deadline without the gin keys
+			req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, upstream.URL, nil)
+			resp, err := client.Do(req)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+			c.Status(http.StatusOK)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, <-budget)
+}
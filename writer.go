@@ -3,12 +3,34 @@ package timeout
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// BufferedWriter is implemented by writers that hold a handler's response
+// until the middleware decides whether to flush or discard it.
+type BufferedWriter interface {
+	gin.ResponseWriter
+	// FlushBuffer copies the buffered headers and body to the underlying writer.
+	FlushBuffer() error
+	// MarkTimeout flags the writer as timed out so later writes are dropped.
+	MarkTimeout()
+	// FreeBuffer releases the buffer pointer.
+	FreeBuffer()
+	// Committed reports whether output already reached the underlying writer,
+	// in which case the response can no longer be replaced on timeout.
+	Committed() bool
+}
+
+// WriterFactory creates the BufferedWriter used for a single request.
+type WriterFactory func(w gin.ResponseWriter, buf *bytes.Buffer) BufferedWriter
+
 // Writer is a writer with memory buffer
 type Writer struct {
 	gin.ResponseWriter
@@ -18,6 +40,53 @@ type Writer struct {
 	timeout      bool
 	wroteHeaders bool
 	code         int
+
+	// passthrough lists the content types streamed without buffering
+	passthrough []string
+	streaming   bool
+	// streamAfter is the body size beyond which the response is streamed
+	streamAfter int
+	// writeThrough streams the response from the first byte
+	writeThrough bool
+	// streamReaders streams responses copied from a reader, e.g. with
+	// c.DataFromReader, chunk by chunk until deadline
+	streamReaders bool
+	deadline      func() time.Time
+
+	// handlerBytes counts every byte the handler wrote, lateCode the status
+	// it set after the timeout, both describe how an abandoned handler ended
+	handlerBytes int
+	lateCode     int
+	// onLateWrite is called, with the lock held, for writes after the timeout
+	onLateWrite func(n int)
+	// onWrite is called, with the lock held, for each write that made progress
+	onWrite func()
+	// closedErr makes writes after the timeout fail with ErrWriterClosed
+	closedErr bool
+	// background keeps buffering the handler output after the client got
+	// another response, nothing reaches the underlying writer anymore
+	background bool
+
+	// size and written mirror gin's Size and Written for the buffered body
+	size    int
+	written bool
+
+	// keepHeaders copies the headers to seen on each call of the handler, so
+	// they can be read once it timed out while it may still change them
+	keepHeaders bool
+	seen        http.Header
+
+	// spillThreshold is the body size beyond which it moves to a file in spillDir
+	spillThreshold int
+	spillDir       string
+	spill          *os.File
+}
+
+var chunkPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 32<<10)
+		return &b
+	},
 }
 
 // NewWriter will return a timeout.Writer pointer
@@ -30,11 +99,247 @@ func (w *Writer) Write(data []byte) (int, error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	w.keepHeadersSeen()
+	w.handlerBytes += len(data)
 	if w.timeout || w.body == nil {
+		if w.timeout && w.onLateWrite != nil {
+			w.onLateWrite(len(data))
+		}
+		if w.timeout && w.closedErr {
+			return 0, ErrWriterClosed
+		}
 		return 0, nil
 	}
 
-	return w.body.Write(data)
+	w.written = true
+	var n int
+	var err error
+	if w.background {
+		n, err = w.buffer(data)
+	} else if w.streaming || w.writeThrough || w.shouldStream() || w.exceedsStreamAfter(len(data)) {
+		n, err = w.stream(data)
+	} else {
+		n, err = w.buffer(data)
+	}
+	w.size += n
+	if n > 0 && w.onWrite != nil {
+		w.onWrite()
+	}
+	return n, err
+}
+
+// WriteHeaderNow marks the response as written, the header itself is only
+// sent to the client once the buffered response is flushed
+func (w *Writer) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.keepHeadersSeen()
+	if w.timeout || w.body == nil {
+		return
+	}
+	w.written = true
+	if w.streaming {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Size returns the number of body bytes written so far, -1 if nothing was written
+func (w *Writer) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.body == nil && !w.timeout {
+		return w.ResponseWriter.Size()
+	}
+	if !w.written {
+		return -1
+	}
+	return w.size
+}
+
+// Written reports whether the response body or header was written
+func (w *Writer) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.body == nil && !w.timeout {
+		return w.ResponseWriter.Written()
+	}
+	return w.written
+}
+
+// shouldStream reports whether the declared content type is one to pass through
+func (w *Writer) shouldStream() bool {
+	if len(w.passthrough) == 0 {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(w.headers.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range w.passthrough {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// exceedsStreamAfter reports whether writing n more bytes grows the body past streamAfter
+func (w *Writer) exceedsStreamAfter(n int) bool {
+	return w.streamAfter > 0 && w.body.Len()+n > w.streamAfter
+}
+
+// stream switches the writer to pass-through mode: cached headers and any
+// buffered bytes are sent first, then data is written directly and flushed
+// for passed through content types
+func (w *Writer) stream(data []byte) (int, error) {
+	if !w.streaming {
+		w.streaming = true
+		dst := w.ResponseWriter.Header()
+		for k, vv := range w.headers {
+			dst[k] = vv
+		}
+		if w.body.Len() > 0 || w.spill != nil {
+			if err := w.writeBuffered(); err != nil {
+				return 0, err
+			}
+			w.body.Reset()
+			w.closeSpill()
+		}
+	}
+
+	n, err := w.ResponseWriter.Write(data)
+	if w.shouldStream() {
+		w.ResponseWriter.Flush()
+	}
+	return n, err
+}
+
+// Flush sends buffered data to the client when the content type is passed
+// through and flushes the underlying writer once the response streams. A
+// buffered response stays buffered, and nothing happens once the writer
+// timed out.
+func (w *Writer) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.keepHeadersSeen()
+	if w.timeout || w.background {
+		return
+	}
+	if w.body != nil && !w.streaming && w.shouldStream() {
+		_, _ = w.stream(nil)
+		return
+	}
+	if w.streaming || w.body == nil {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// flushPending sends what the handler wrote so far to the client and streams
+// the rest, nothing is sent before the handler wrote anything
+func (w *Writer) flushPending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timeout || w.background || w.body == nil || !w.written {
+		return
+	}
+	if !w.streaming {
+		if _, err := w.stream(nil); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Committed reports whether output was already streamed to the underlying writer
+func (w *Writer) Committed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.streaming
+}
+
+// ReadFrom implements io.ReaderFrom so io.Copy into the writer reuses pooled
+// chunks instead of allocating its own, the lock is only held per chunk so a
+// timeout is never delayed by a slow reader
+func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
+	if w.streamReaders {
+		return w.copyChunks(r, w.writeChunk)
+	}
+	return w.copyChunks(r, w.Write)
+}
+
+// copyChunks copies r with write in pooled chunks
+func (w *Writer) copyChunks(r io.Reader, write func([]byte) (int, error)) (int64, error) {
+	chunk := chunkPool.Get().(*[]byte)
+	defer chunkPool.Put(chunk)
+
+	var total int64
+	for {
+		n, rerr := r.Read(*chunk)
+		if n > 0 {
+			written, werr := write((*chunk)[:n])
+			total += int64(written)
+			if werr != nil {
+				return total, werr
+			}
+			if written < n {
+				// the writer timed out, stop copying
+				return total, nil
+			}
+		}
+		if rerr == io.EOF {
+			return total, nil
+		}
+		if rerr != nil {
+			return total, rerr
+		}
+	}
+}
+
+// writeChunk streams a chunk of a reader-backed response straight to the
+// underlying writer, the write may take at most the time left before the
+// deadline. Once the timeout hit, the rest of the stream fails with ErrTimeout.
+func (w *Writer) writeChunk(data []byte) (int, error) {
+	w.mu.Lock()
+	if w.background || w.body == nil && !w.timeout {
+		w.mu.Unlock()
+		return w.Write(data)
+	}
+	defer w.mu.Unlock()
+
+	w.keepHeadersSeen()
+	w.handlerBytes += len(data)
+	if w.timeout {
+		if w.onLateWrite != nil {
+			w.onLateWrite(len(data))
+		}
+		return 0, ErrTimeout
+	}
+	rc := http.NewResponseController(w.ResponseWriter)
+	if w.deadline != nil {
+		deadline := w.deadline()
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, ErrTimeout
+		}
+		if err := rc.SetWriteDeadline(deadline); err == nil {
+			defer func() { _ = rc.SetWriteDeadline(time.Time{}) }()
+		}
+	}
+
+	w.written = true
+	n, err := w.stream(data)
+	w.size += n
+	if n > 0 && w.onWrite != nil {
+		w.onWrite()
+	}
+	if err != nil {
+		return n, err
+	}
+	w.ResponseWriter.Flush()
+	return n, nil
 }
 
 // WriteHeader sends an HTTP response header with the provided status code.
@@ -44,6 +349,15 @@ func (w *Writer) WriteHeader(code int) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	w.keepHeadersSeen()
+	if w.timeout && code > 0 {
+		if w.lateCode == 0 {
+			w.lateCode = code
+		}
+		if w.onLateWrite != nil {
+			w.onLateWrite(0)
+		}
+	}
 	if w.timeout || w.wroteHeaders {
 		return
 	}
@@ -57,7 +371,9 @@ func (w *Writer) WriteHeader(code int) {
 	checkWriteHeaderCode(code)
 
 	w.writeHeader(code)
-	w.ResponseWriter.WriteHeader(code)
+	if !w.background {
+		w.ResponseWriter.WriteHeader(code)
+	}
 }
 
 func (w *Writer) writeHeader(code int) {
@@ -67,31 +383,210 @@ func (w *Writer) writeHeader(code int) {
 
 // Header will get response headers
 func (w *Writer) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.keepHeadersSeen()
 	return w.headers
 }
 
+// keepHeadersSeen copies the headers to seen if enabled, with the lock held.
+// The handler sets headers on the map without the lock, a copy taken when it
+// calls the writer is what can safely be read after the timeout.
+func (w *Writer) keepHeadersSeen() {
+	if w.keepHeaders && !w.timeout {
+		w.seen = w.headers.Clone()
+	}
+}
+
+// timedOutHeader returns the headers as the handler left them at its last
+// call of the writer before the timeout, nil unless keepHeaders is set
+func (w *Writer) timedOutHeader() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.seen.Clone()
+}
+
 // WriteString will write string to response body
 func (w *Writer) WriteString(s string) (int, error) {
 	return w.Write([]byte(s))
 }
 
+// FlushBuffer will copy cached headers and buffered body to the underlying writer
+func (w *Writer) FlushBuffer() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// trailers must only reach the underlying writer once the body is written
+	trailers := declaredTrailers(w.headers)
+	dst := w.ResponseWriter.Header()
+	for k, vv := range w.headers {
+		if isTrailer(k, trailers) {
+			continue
+		}
+		dst[k] = vv
+	}
+
+	if err := w.writeBuffered(); err != nil {
+		return err
+	}
+
+	for k, vv := range w.headers {
+		if isTrailer(k, trailers) {
+			dst[k] = vv
+		}
+	}
+	return nil
+}
+
+// declaredTrailers returns the canonical keys announced in the Trailer header
+func declaredTrailers(h http.Header) map[string]struct{} {
+	var trailers map[string]struct{}
+	for _, v := range h.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k == "" {
+				continue
+			}
+			if trailers == nil {
+				trailers = make(map[string]struct{})
+			}
+			trailers[http.CanonicalHeaderKey(k)] = struct{}{}
+		}
+	}
+	return trailers
+}
+
+// isTrailer reports whether a header key holds a trailer value
+func isTrailer(k string, trailers map[string]struct{}) bool {
+	if strings.HasPrefix(k, http.TrailerPrefix) {
+		return true
+	}
+	_, ok := trailers[k]
+	return ok
+}
+
+// MarkTimeout will mark the writer as timed out and drop further writes
+func (w *Writer) MarkTimeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.timeout = true
+}
+
+// moveToBackground keeps buffering the handler output without ever writing
+// to the underlying writer, it reports false if output was already streamed
+func (w *Writer) moveToBackground() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.streaming || w.timeout {
+		return false
+	}
+	w.background = true
+	return true
+}
+
+// setLateWriteHook sets the function called for writes after the timeout
+func (w *Writer) setLateWriteHook(f func(n int)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.onLateWrite = f
+}
+
+// Partial is what a handler had buffered when it timed out
+type Partial struct {
+	// Status is the status code the handler set, 0 if none
+	Status int
+	// Header holds the headers the handler set
+	Header http.Header
+	// Body is the body the handler had written
+	Body []byte
+}
+
+// partial returns a copy of the buffered response, after the timeout with
+// the headers as the handler left them at its last call of the writer
+func (w *Writer) partial() Partial {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	header := w.headers
+	if w.timeout {
+		header = w.seen
+	}
+	return Partial{Status: w.code, Header: header.Clone(), Body: w.bufferedBytes()}
+}
+
+// rewriteBody lets f replace the buffered body before it is flushed. It
+// reports false without calling f when there is no buffered body or when it
+// spilled to disk, so a large body is never read back into memory.
+func (w *Writer) rewriteBody(f func(body *bytes.Buffer) error) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.body == nil || w.streaming || w.spill != nil {
+		return false, nil
+	}
+	if err := f(w.body); err != nil {
+		return true, err
+	}
+	w.size = w.body.Len()
+	return true, nil
+}
+
 // FreeBuffer will release buffer pointer
 func (w *Writer) FreeBuffer() {
-	// if not reset body,old bytes will put in bufPool
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.body == nil {
+		return
+	}
+	// if not reset body,old bytes will put in the buffer pool
 	w.body.Reset()
 	w.body = nil
+	w.closeSpill()
 }
 
 // Status we must override Status func here,
 // or the http status code returned by gin.Context.Writer.Status()
 // will always be 200 in other custom gin middlewares.
 func (w *Writer) Status() int {
-	if w.code == 0 || w.timeout {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timeout {
+		// the underlying writer may already serve another request
+		return w.result()
+	}
+	if w.code == 0 {
 		return w.ResponseWriter.Status()
 	}
 	return w.code
 }
 
+// handlerResult returns the status and body size the handler produced,
+// including what it wrote after the timeout
+func (w *Writer) handlerResult() (status, size int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.result(), w.handlerBytes
+}
+
+// result returns the status the handler set, with the lock held
+func (w *Writer) result() int {
+	switch {
+	case w.code != 0:
+		return w.code
+	case w.lateCode != 0:
+		return w.lateCode
+	default:
+		return http.StatusOK
+	}
+}
+
 func checkWriteHeaderCode(code int) {
 	if code < 100 || code > 999 {
 		panic(fmt.Sprintf("invalid http status code: %d", code))
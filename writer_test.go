@@ -1,11 +1,15 @@
 package timeout
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +17,14 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// newTestWriter returns a Writer over a recorder, FlushBuffer moves the
+// buffered output into it
+func newTestWriter() (*Writer, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	return NewWriter(c.Writer, &bytes.Buffer{}), rec
+}
+
 func TestWriteHeader(t *testing.T) {
 	code1 := 99
 	errmsg1 := fmt.Sprintf("invalid http status code: %d", code1)
@@ -213,3 +225,406 @@ func TestHTTPStatusCode(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTestWriter(t *testing.T) {
+	writer, rec := newTestWriter()
+	writer.Header().Set("X-Test", "1")
+	writer.WriteHeader(http.StatusCreated)
+	_, err := writer.WriteString("hello")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, writer.Status())
+	assert.Equal(t, "", rec.Body.String())
+
+	assert.NoError(t, writer.FlushBuffer())
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("X-Test"))
+	assert.Equal(t, "hello", rec.Body.String())
+}
+
+func TestWriterTrailers(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithHandler(func(c *gin.Context) {
+			c.Header("Trailer", "Grpc-Status, X-Checksum")
+			c.String(http.StatusOK, "body")
+			c.Header("Grpc-Status", "0")
+			c.Header("X-Checksum", "abc")
+			c.Writer.Header().Set(http.TrailerPrefix+"X-Late", "1")
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, "body", w.Body.String())
+	assert.Empty(t, res.Header.Get("Grpc-Status"))
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "abc", res.Trailer.Get("X-Checksum"))
+	assert.Equal(t, "1", res.Trailer.Get("X-Late"))
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	writer, rec := newTestWriter()
+	var _ io.ReaderFrom = writer
+
+	payload := strings.Repeat("x", 100<<10)
+	// hide strings.Reader's WriteTo so io.Copy goes through ReadFrom
+	n, err := io.Copy(writer, struct{ io.Reader }{strings.NewReader(payload)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(payload)), n)
+
+	assert.NoError(t, writer.FlushBuffer())
+	assert.Equal(t, payload, rec.Body.String())
+
+	writer.MarkTimeout()
+	n, err = writer.ReadFrom(strings.NewReader(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+// slowReader yields its chunks one per read, waiting delay before each
+type slowReader struct {
+	chunks []string
+	delay  time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestWithReaderStreaming(t *testing.T) {
+	var copyErr error
+	done := make(chan struct{})
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			defer close(done)
+			reader := &slowReader{chunks: []string{"a", "b", "c", "d", "e"}, delay: 20 * time.Millisecond}
+			c.DataFromReader(http.StatusOK, -1, "text/plain", reader, nil)
+			copyErr = c.Errors.Last()
+		}),
+		WithReaderStreaming(),
+	))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-done
+
+	// the chunks sent before the deadline went out as they were read, the
+	// status can no longer change
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Flushed)
+	body := w.Body.String()
+	assert.NotEmpty(t, body)
+	assert.Less(t, len(body), len("abcde"))
+	assert.True(t, strings.HasPrefix("abcde", body), body)
+	assert.ErrorIs(t, copyErr, ErrTimeout)
+}
+
+func TestWriterSSEPassthrough(t *testing.T) {
+	w := httptest.NewRecorder()
+	streamed := make(chan string, 1)
+	release := make(chan struct{})
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Millisecond),
+		WithHandler(func(c *gin.Context) {
+			c.SSEvent("message", "hello")
+			streamed <- w.Body.String()
+			<-release
+		}),
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+	close(release)
+
+	assert.Equal(t, "event:message\ndata:hello\n\n", <-streamed)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "event:message\ndata:hello\n\n", w.Body.String())
+	assert.True(t, w.Flushed)
+}
+
+func TestWriterPassthroughContentTypes(t *testing.T) {
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithPassthroughContentTypes(),
+		WithHandler(func(c *gin.Context) {
+			c.SSEvent("message", "hello")
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, "event:message\ndata:hello\n\n", w.Body.String())
+	assert.False(t, w.Flushed)
+}
+
+func TestWriterSpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+	payload := strings.Repeat("y", 4096)
+	var spilled []os.DirEntry
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(1*time.Second),
+		WithSpillToDisk(1024, dir),
+		WithHandler(func(c *gin.Context) {
+			c.String(http.StatusOK, payload[:512])
+			c.String(http.StatusOK, payload[512:])
+			spilled, _ = os.ReadDir(dir)
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, payload, w.Body.String())
+	assert.Len(t, spilled, 1)
+
+	left, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, left)
+}
+
+func TestWriterStreamAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		size int
+		code int
+	}{
+		{"small response is replaced", 8, http.StatusRequestTimeout},
+		{"large response is streamed", 64, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			release := make(chan struct{})
+			r := gin.New()
+			r.GET("/", New(
+				WithTimeout(20*time.Millisecond),
+				WithStreamAfter(16),
+				WithHandler(func(c *gin.Context) {
+					c.String(http.StatusOK, strings.Repeat("z", tc.size))
+					<-release
+				}),
+			))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.ServeHTTP(w, req)
+			close(release)
+
+			assert.Equal(t, tc.code, w.Code)
+			if tc.code == http.StatusOK {
+				assert.Equal(t, strings.Repeat("z", tc.size), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWriterSizeAndWritten(t *testing.T) {
+	writer, rec := newTestWriter()
+	assert.Equal(t, -1, writer.Size())
+	assert.False(t, writer.Written())
+
+	writer.WriteHeaderNow()
+	assert.True(t, writer.Written())
+	assert.Equal(t, 0, writer.Size())
+	assert.False(t, rec.Flushed)
+	assert.Equal(t, "", rec.Body.String())
+
+	_, err := writer.WriteString("hello")
+	assert.NoError(t, err)
+	_, err = writer.Write([]byte(" world"))
+	assert.NoError(t, err)
+	assert.Equal(t, len("hello world"), writer.Size())
+
+	assert.NoError(t, writer.FlushBuffer())
+	writer.FreeBuffer()
+	assert.Equal(t, len("hello world"), writer.Size())
+	assert.True(t, writer.Written())
+}
+
+func TestWriterFlushAfterTimeout(t *testing.T) {
+	writer, rec := newTestWriter()
+	writer.MarkTimeout()
+	writer.Flush()
+	assert.False(t, rec.Flushed)
+}
+
+func TestWriterFreeBufferWhileWriting(t *testing.T) {
+	writer, _ := newTestWriter()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, _ = writer.WriteString("x")
+		}
+	}()
+
+	writer.MarkTimeout()
+	writer.FreeBuffer()
+	writer.FreeBuffer()
+	<-done
+	n, err := writer.WriteString("late")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestWriterWriteThrough(t *testing.T) {
+	w := httptest.NewRecorder()
+	direct := make(chan string, 1)
+	var errs []*gin.Error
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		errs = c.Errors
+	})
+	r.GET("/:wait", New(
+		WithTimeout(20*time.Millisecond),
+		WithWriteThrough(),
+		WithHandler(func(c *gin.Context) {
+			if c.Param("wait") == "first" {
+				<-release
+			}
+			c.String(http.StatusOK, "partial")
+			direct <- w.Body.String()
+			<-release
+		}),
+	))
+
+	req := httptest.NewRequest(http.MethodGet, "/later", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, "partial", <-direct)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "partial", w.Body.String())
+	assert.Len(t, errs, 1)
+
+	w2 := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/first", nil)
+	r.ServeHTTP(w2, req)
+	close(release)
+
+	assert.Equal(t, http.StatusRequestTimeout, w2.Code)
+}
+
+func TestWriterOnLateWrite(t *testing.T) {
+	late := make(chan int, 2)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(5 * time.Millisecond)
+			c.String(http.StatusOK, "ignored")
+		}),
+		WithOnLateWrite(func(c *gin.Context, n int) {
+			late <- n
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.Equal(t, 0, <-late)
+	assert.Equal(t, len("ignored"), <-late)
+}
+
+func TestWriterClosedError(t *testing.T) {
+	errs := make(chan error, 1)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(50*time.Microsecond),
+		WithWriterClosedError(),
+		WithHandler(func(c *gin.Context) {
+			time.Sleep(5 * time.Millisecond)
+			_, err := c.Writer.Write([]byte("ignored"))
+			errs <- err
+		}),
+	))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestTimeout, w.Code)
+	assert.ErrorIs(t, <-errs, ErrWriterClosed)
+}
+
+func TestServeContentRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	cache := NewStaleCache(10)
+	r := gin.New()
+	r.GET("/", New(
+		WithTimeout(time.Second),
+		WithHandler(func(c *gin.Context) {
+			http.ServeContent(c.Writer, c.Request, "file.txt", time.Unix(1e9, 0), strings.NewReader(content))
+		}),
+		WithETag(),
+		WithCompression(1),
+		WithDigest(DigestSHA256),
+		WithStaleCache(cache, ""),
+	))
+
+	for _, tc := range []struct {
+		rng, contentRange, body string
+	}{
+		{"bytes=10-19", "bytes 10-19/100", content[10:20]},
+		{"bytes=-5", "bytes 95-99/100", content[95:]},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Range", tc.rng)
+		req.Header.Set("Accept-Encoding", "gzip")
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPartialContent, w.Code, tc.rng)
+		assert.Equal(t, tc.contentRange, w.Header().Get("Content-Range"))
+		assert.Equal(t, strconv.Itoa(len(tc.body)), w.Header().Get("Content-Length"))
+		assert.Equal(t, tc.body, w.Body.String())
+		// nothing computed over the full representation may describe a range
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Empty(t, w.Header().Get("ETag"))
+		assert.Empty(t, w.Header().Get("Digest"))
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	_, cached := cache.load(c)
+	assert.False(t, cached, "a 206 must not be cached")
+
+	// several ranges are sent as multipart/byteranges
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=0-4,20-24")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges"))
+	assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+	assert.Contains(t, w.Body.String(), "Content-Range: bytes 20-24/100")
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=500-")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	assert.Equal(t, "bytes */100", w.Header().Get("Content-Range"))
+}